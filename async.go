@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/logging"
+	"github.com/ArjenSchwarz/igor/platforms"
+	"github.com/ArjenSchwarz/igor/plugins"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// asyncJob is a request that is handled in the background, after which the
+// response is sent to the request's response_url. For events the response is
+// sent through the Web API instead, and actions are handled by the plugin
+// that owns them.
+type asyncJob struct {
+	Request slack.Request `json:"request"`
+	Event   *eventReply   `json:"event,omitempty"`
+	Action  *actionJob    `json:"action,omitempty"`
+}
+
+// dispatchAsync hands a job off to be run in the background. It depends on
+// the mode Igor runs in, and when it's nil everything is handled directly.
+var dispatchAsync func(job asyncJob) error
+
+// backgroundJobs tracks the jobs running in goroutines, so the server can
+// wait for them when it shuts down. Once it's stopped no new jobs are
+// started, so none are added while the server waits.
+var backgroundJobs = struct {
+	sync.Mutex
+	running sync.WaitGroup
+	stopped bool
+}{}
+
+// errBackgroundStopped is returned for jobs dispatched while the server shuts
+// down, which are then handled directly
+var errBackgroundStopped = errors.New("background jobs are stopped")
+
+// dispatchGoroutine runs the job in a goroutine, for use in server mode
+func dispatchGoroutine(job asyncJob) error {
+	backgroundJobs.Lock()
+	defer backgroundJobs.Unlock()
+	if backgroundJobs.stopped {
+		return errBackgroundStopped
+	}
+	backgroundJobs.running.Add(1)
+	go func() {
+		defer backgroundJobs.running.Done()
+		runAsync(context.Background(), job)
+	}()
+	return nil
+}
+
+// waitForBackgroundJobs stops starting jobs in goroutines, and waits for the
+// ones that are running to finish
+func waitForBackgroundJobs() {
+	backgroundJobs.Lock()
+	backgroundJobs.stopped = true
+	backgroundJobs.Unlock()
+	backgroundJobs.running.Wait()
+}
+
+// dispatchLambda asynchronously invokes the running Lambda function with the
+// job, as a Lambda function is frozen once it has returned its response
+func dispatchLambda(job asyncJob) error {
+	payload, err := json.Marshal(lambdaEvent{Job: &job})
+	if err != nil {
+		return err
+	}
+	sess, err := session.NewSession()
+	if err != nil {
+		return err
+	}
+	svc := lambda.New(sess)
+	params := &lambda.InvokeInput{
+		FunctionName:   aws.String(os.Getenv("AWS_LAMBDA_FUNCTION_NAME")),
+		InvocationType: aws.String(lambda.InvocationTypeEvent),
+		Payload:        payload,
+	}
+	_, err = svc.Invoke(params)
+	return err
+}
+
+// delay checks whether the request should be handled in the background. If
+// so, the request is dispatched and true is returned.
+func delay(ctx context.Context, request slack.Request, config config.Config) bool {
+	if dispatchAsync == nil || request.ResponseURL == "" || len(config.Async) == 0 {
+		return false
+	}
+	matchRequest := request
+	if matchRequest.Text != "" && matchRequest.Text[0] == '!' {
+		matchRequest.Text = matchRequest.Text[1:]
+	}
+	matches := plugins.MatchingPlugins(matchRequest, config)
+	if len(matches) > 0 && config.RunsAsync(matches[0].Route.Plugin) {
+		if err := dispatchAsync(asyncJob{Request: request}); err != nil {
+			logging.FromContext(ctx).Error("Failed to dispatch the request to the background", err)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// runAsync does the actual work for a job and sends the result to where it
+// needs to go
+func runAsync(ctx context.Context, job asyncJob) {
+	request := job.Request
+	ctx = requestContext(ctx, &request)
+	logger := logging.FromContext(ctx)
+	config, err := config.TeamConfig(request.TeamID)
+	response := slack.Response{}
+	if err != nil {
+		logger.Error("Failed to load the configuration", err)
+		response = slack.SomethingWrongResponse(request)
+	} else if job.Action != nil {
+		response = determineActionResponse(ctx, request, *job.Action, config)
+	} else {
+		response = determineResponse(ctx, request, config)
+	}
+	if job.Event != nil {
+		response.Escape()
+		response.PrepareBlocks(config.BlockKit)
+		err = replyToEvent(*job.Event, response, config)
+	} else {
+		adapter, ok := platforms.GetAdapter(request.Platform)
+		if !ok {
+			adapter = platforms.DefaultAdapter(config)
+		}
+		err = adapter.PostResponse(request.ResponseURL, response, config)
+	}
+	if err != nil {
+		logger.Error("Failed to send the delayed response", err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package audit keeps a record of every command sent to Igor: who sent it,
+// where, which plugin handled it, and what the outcome was. Records are
+// written to the configured sinks, and the ones in a file can be queried.
+package audit
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/logging"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// Record describes a single command and how it was handled. Plugin, Command,
+// and Language are empty when no plugin handled the command.
+type Record struct {
+	Time        time.Time `json:"time"`
+	RequestID   string    `json:"request_id"`
+	Platform    string    `json:"platform,omitempty"`
+	TeamID      string    `json:"team_id"`
+	ChannelID   string    `json:"channel_id"`
+	ChannelName string    `json:"channel_name,omitempty"`
+	UserID      string    `json:"user_id"`
+	UserName    string    `json:"user_name,omitempty"`
+	Text        string    `json:"text"`
+	Plugin      string    `json:"plugin,omitempty"`
+	Command     string    `json:"command,omitempty"`
+	Language    string    `json:"language,omitempty"`
+	Outcome     string    `json:"outcome"`
+	LatencyMS   int64     `json:"latency_ms"`
+}
+
+// NewRecord starts a record for the request, at the current time
+func NewRecord(request slack.Request) Record {
+	return Record{
+		Time:        time.Now().UTC(),
+		RequestID:   request.RequestID,
+		Platform:    request.Platform,
+		TeamID:      request.TeamID,
+		ChannelID:   request.ChannelID,
+		ChannelName: request.ChannelName,
+		UserID:      request.UserID,
+		UserName:    request.UserName,
+		Text:        request.Text,
+	}
+}
+
+// Finish sets the outcome of the command, and how long it took since the
+// record was started
+func (record *Record) Finish(outcome string) {
+	record.Outcome = outcome
+	record.LatencyMS = time.Since(record.Time).Nanoseconds() / int64(time.Millisecond)
+}
+
+// Sink stores audit records
+type Sink interface {
+	Write(ctx context.Context, record Record) error
+}
+
+// Querier is a sink that the stored records can be retrieved from
+type Querier interface {
+	Query(filter Filter) ([]Record, error)
+}
+
+// Filter selects records. Fields that aren't set match every record.
+type Filter struct {
+	// User is the ID or name of the user who sent the command
+	User string
+	// Plugin is the name of the plugin that handled the command
+	Plugin string
+	// Since and Until limit the time the command was sent
+	Since time.Time
+	Until time.Time
+}
+
+// Matches checks if the record is selected by the filter
+func (filter Filter) Matches(record Record) bool {
+	if filter.User != "" && !strings.EqualFold(filter.User, record.UserID) && !strings.EqualFold(filter.User, record.UserName) {
+		return false
+	}
+	if filter.Plugin != "" && !strings.EqualFold(filter.Plugin, record.Plugin) {
+		return false
+	}
+	if !filter.Since.IsZero() && record.Time.Before(filter.Since) {
+		return false
+	}
+	if !filter.Until.IsZero() && record.Time.After(filter.Until) {
+		return false
+	}
+	return true
+}
+
+var (
+	sinks     []Sink
+	sinksLock sync.Mutex
+)
+
+// SetSinks replaces the sinks records are written to
+func SetSinks(configured ...Sink) {
+	sinksLock.Lock()
+	sinks = configured
+	sinksLock.Unlock()
+}
+
+// Sinks creates the sinks in the audit configuration. The sinks that could
+// be created are returned together with the error for the one that couldn't.
+func Sinks(auditConfig config.AuditConfig) ([]Sink, error) {
+	configured := []Sink{}
+	if auditConfig.File != "" {
+		configured = append(configured, NewFileSink(auditConfig.File))
+	}
+	if auditConfig.Stdout {
+		configured = append(configured, NewWriterSink(os.Stdout))
+	}
+	if auditConfig.DynamoDB != "" {
+		sink, err := NewDynamoDBSink(auditConfig.DynamoDB)
+		if err != nil {
+			return configured, err
+		}
+		configured = append(configured, sink)
+	}
+	return configured, nil
+}
+
+// Log writes the record to every sink. A sink that fails doesn't stop the
+// others, and the failure is logged as the request continues regardless.
+func Log(ctx context.Context, record Record) {
+	sinksLock.Lock()
+	configured := sinks
+	sinksLock.Unlock()
+	for _, sink := range configured {
+		if err := sink.Write(ctx, record); err != nil {
+			logging.FromContext(ctx).Error("Failed to write the audit record", err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/audit"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestNewRecord(t *testing.T) {
+	request := slack.Request{RequestID: "abcd1234", TeamID: "T1", ChannelID: "C1", ChannelName: "general", UserID: "U1", UserName: "arjen", Text: "forget cat"}
+	record := audit.NewRecord(request)
+	if record.RequestID != "abcd1234" || record.UserName != "arjen" || record.Text != "forget cat" {
+		t.Errorf("Expected the details of the request, actual %+v", record)
+	}
+	record.Finish("answered")
+	if record.Outcome != "answered" || record.LatencyMS < 0 {
+		t.Errorf("Expected the outcome to be set, actual %+v", record)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	record := audit.Record{Time: now, UserID: "U1", UserName: "arjen", Plugin: "remember"}
+	var filterTests = []struct {
+		filter  audit.Filter
+		matches bool
+	}{
+		{audit.Filter{}, true},
+		{audit.Filter{User: "U1"}, true},
+		{audit.Filter{User: "Arjen"}, true},
+		{audit.Filter{User: "U2"}, false},
+		{audit.Filter{Plugin: "remember"}, true},
+		{audit.Filter{Plugin: "xkcd"}, false},
+		{audit.Filter{Since: now.Add(-time.Hour), Until: now.Add(time.Hour)}, true},
+		{audit.Filter{Since: now.Add(time.Minute)}, false},
+		{audit.Filter{Until: now.Add(-time.Minute)}, false},
+	}
+	for _, tt := range filterTests {
+		if tt.filter.Matches(record) != tt.matches {
+			t.Errorf("%+v: expected match %v", tt.filter, tt.matches)
+		}
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "igor-audit")
+	if err != nil {
+		t.Fatal("Problem creating a directory", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+	sink := audit.NewFileSink(path)
+	records, err := sink.Query(audit.Filter{})
+	if err != nil || len(records) != 0 {
+		t.Fatalf("Expected no records before anything is written, actual %v (%v)", records, err)
+	}
+	for _, record := range []audit.Record{
+		{UserID: "U1", Plugin: "remember", Command: "forget", Outcome: "answered"},
+		{UserID: "U2", Plugin: "xkcd", Command: "xkcd", Outcome: "answered"},
+		{UserID: "U1", Plugin: "xkcd", Command: "xkcd_random", Outcome: "rate_limited"},
+	} {
+		if err := sink.Write(context.Background(), record); err != nil {
+			t.Fatal("Unexpected error writing a record", err)
+		}
+	}
+	records, err = sink.Query(audit.Filter{User: "U1"})
+	if err != nil {
+		t.Fatal("Unexpected error querying the records", err)
+	}
+	if len(records) != 2 || records[0].Command != "forget" || records[1].Command != "xkcd_random" {
+		t.Errorf("Expected the records of U1 in order, actual %+v", records)
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal("Unexpected error reading the file", err)
+	}
+	if lines := bytes.Count(contents, []byte("\n")); lines != 3 {
+		t.Errorf("Expected a line per record, actual %d", lines)
+	}
+}
+
+// failingSink is a Sink that can't store records
+type failingSink struct{}
+
+func (failingSink) Write(ctx context.Context, record audit.Record) error {
+	return errors.New("disk full")
+}
+
+func TestLog(t *testing.T) {
+	var buffer bytes.Buffer
+	audit.SetSinks(failingSink{}, audit.NewWriterSink(&buffer))
+	defer audit.SetSinks()
+	audit.Log(context.Background(), audit.Record{RequestID: "abcd1234", Outcome: "answered"})
+	var record audit.Record
+	if err := json.Unmarshal(buffer.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON line, actual %q", buffer.String())
+	}
+	if record.RequestID != "abcd1234" {
+		t.Errorf("Expected the record to be written after a sink failed, actual %+v", record)
+	}
+}
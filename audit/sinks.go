@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/ArjenSchwarz/igor/metrics"
+)
+
+// WriterSink writes records as JSON lines, like to the standard output
+type WriterSink struct {
+	lock   sync.Mutex
+	output io.Writer
+}
+
+// NewWriterSink creates a WriterSink for the writer
+func NewWriterSink(output io.Writer) *WriterSink {
+	return &WriterSink{output: output}
+}
+
+// Write writes the record as a single line of JSON
+func (sink *WriterSink) Write(ctx context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	_, err = sink.output.Write(append(line, '\n'))
+	return err
+}
+
+// FileSink appends records as JSON lines to a file, which is created when
+// it doesn't exist yet
+type FileSink struct {
+	Path string
+	lock sync.Mutex
+}
+
+// NewFileSink creates a FileSink for the file at the path
+func NewFileSink(path string) *FileSink {
+	return &FileSink{Path: path}
+}
+
+// Write appends the record to the file
+func (sink *FileSink) Write(ctx context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	file, err := os.OpenFile(sink.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Query reads the records in the file that are selected by the filter, in
+// the order they were written. Lines that aren't records are skipped.
+func (sink *FileSink) Query(filter Filter) ([]Record, error) {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	records := []Record{}
+	file, err := os.Open(sink.Path)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return records, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if filter.Matches(record) {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+// DynamoDBSink stores records in a DynamoDB table. The table needs a string
+// partition key called "request_id".
+type DynamoDBSink struct {
+	Table string
+	svc   *dynamodb.DynamoDB
+}
+
+// NewDynamoDBSink creates a DynamoDBSink for the table, with the client it
+// uses for every record
+func NewDynamoDBSink(table string) (*DynamoDBSink, error) {
+	sess, err := session.NewSession(&aws.Config{HTTPClient: &http.Client{Transport: metrics.Transport{}}})
+	if err != nil {
+		return nil, err
+	}
+	return &DynamoDBSink{Table: table, svc: dynamodb.New(sess)}, nil
+}
+
+// Write stores the record as an item. Empty values are left out, as
+// DynamoDB doesn't accept them in every case.
+func (sink *DynamoDBSink) Write(ctx context.Context, record Record) error {
+	item := map[string]*dynamodb.AttributeValue{
+		"time":       {S: aws.String(record.Time.Format(time.RFC3339Nano))},
+		"latency_ms": {N: aws.String(strconv.FormatInt(record.LatencyMS, 10))},
+	}
+	values := map[string]string{
+		"request_id":   record.RequestID,
+		"platform":     record.Platform,
+		"team_id":      record.TeamID,
+		"channel_id":   record.ChannelID,
+		"channel_name": record.ChannelName,
+		"user_id":      record.UserID,
+		"user_name":    record.UserName,
+		"text":         record.Text,
+		"plugin":       record.Plugin,
+		"command":      record.Command,
+		"language":     record.Language,
+		"outcome":      record.Outcome,
+	}
+	for name, value := range values {
+		if value != "" {
+			item[name] = &dynamodb.AttributeValue{S: aws.String(value)}
+		}
+	}
+	params := &dynamodb.PutItemInput{
+		Item:      item,
+		TableName: aws.String(sink.Table),
+	}
+	_, err := sink.svc.PutItemWithContext(ctx, params)
+	return err
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/audit"
+	"github.com/ArjenSchwarz/igor/config"
+)
+
+// runAudit queries the records in the audit file, and writes the ones
+// selected by the flags in the arguments. The file is the one in the
+// configuration, unless another one is provided.
+func runAudit(args []string, output io.Writer) error {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	flags.SetOutput(output)
+	file := flags.String("file", "", "The audit file to query, instead of the configured one")
+	user := flags.String("user", "", "Only show the commands of the user, by ID or name")
+	plugin := flags.String("plugin", "", "Only show the commands handled by the plugin")
+	since := flags.String("since", "", "Only show commands sent since the time, as a date, an RFC 3339 time, or a duration like 24h")
+	until := flags.String("until", "", "Only show commands sent until the time, in the same formats as since")
+	asJSON := flags.Bool("json", false, "Write the records as JSON lines")
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if *file == "" {
+		generalConfig, err := config.GeneralConfig()
+		if err != nil {
+			return err
+		}
+		*file = generalConfig.Audit.File
+	}
+	if *file == "" {
+		return errors.New("no audit file is configured, set audit.file or use -file")
+	}
+	now := time.Now()
+	filter := audit.Filter{User: *user, Plugin: *plugin}
+	var err error
+	if filter.Since, err = parseAuditTime(*since, now, false); err != nil {
+		return err
+	}
+	if filter.Until, err = parseAuditTime(*until, now, true); err != nil {
+		return err
+	}
+	records, err := audit.NewFileSink(*file).Query(filter)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if *asJSON {
+			line, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(output, string(line))
+			continue
+		}
+		fmt.Fprintln(output, formatAuditRecord(record))
+	}
+	return nil
+}
+
+// parseAuditTime parses a time for the audit filter. A duration is the time
+// that long before now, and an empty value is no limit. A date is the start
+// of the day, or the end of the day when it's the end of the period.
+func parseAuditTime(value string, now time.Time, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	if parsed, err := time.Parse("2006-01-02", value); err == nil {
+		if end {
+			return parsed.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+		}
+		return parsed, nil
+	}
+	return time.Time{}, fmt.Errorf("%q isn't a date, time, or duration", value)
+}
+
+// formatAuditRecord describes the record on a single line
+func formatAuditRecord(record audit.Record) string {
+	user := record.UserID
+	if record.UserName != "" {
+		user = record.UserName + " (" + record.UserID + ")"
+	}
+	channel := record.ChannelID
+	if record.ChannelName != "" {
+		channel = "#" + record.ChannelName
+	}
+	handler := "no plugin"
+	if record.Plugin != "" {
+		handler = record.Plugin + "/" + record.Command
+	}
+	return fmt.Sprintf("%s %s %s in %s: %q -> %s, %s in %dms",
+		record.Time.Local().Format("2006-01-02 15:04:05"),
+		record.TeamID, user, channel, record.Text, handler, record.Outcome, record.LatencyMS)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/platforms"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// cliOptions contains the details used for the requests sent from the
+// command line
+type cliOptions struct {
+	UserName    string
+	UserID      string
+	ChannelName string
+	ChannelID   string
+	TeamDomain  string
+	TeamID      string
+	Colour      bool
+}
+
+// cliAdapter is the platform for requests sent from the command line. It
+// isn't registered with the other platforms, as it mustn't be available as a
+// route.
+type cliAdapter struct {
+	options cliOptions
+	output  io.Writer
+}
+
+// Name returns the name of the platform
+func (cliAdapter) Name() string {
+	return "cli"
+}
+
+// ParseRequest creates a request for the text, using the configured user,
+// channel, and team
+func (adapter cliAdapter) ParseRequest(incoming platforms.Incoming) (slack.Request, error) {
+	return slack.Request{
+		UserName:    adapter.options.UserName,
+		UserID:      adapter.options.UserID,
+		ChannelName: adapter.options.ChannelName,
+		ChannelID:   adapter.options.ChannelID,
+		TeamDomain:  adapter.options.TeamDomain,
+		TeamID:      adapter.options.TeamID,
+		Command:     "/igor",
+		Text:        strings.TrimSpace(incoming.Body),
+		Platform:    "cli",
+	}, nil
+}
+
+// Validate accepts every request, as they're typed by whoever runs Igor
+func (cliAdapter) Validate(request slack.Request, config config.Config) bool {
+	return true
+}
+
+// Render translates the response into text for the terminal
+func (adapter cliAdapter) Render(response slack.Response, config config.Config) interface{} {
+	return renderTerminal(response, adapter.options.Colour)
+}
+
+// PostResponse writes a response that's sent separately to the terminal
+func (adapter cliAdapter) PostResponse(responseURL string, response slack.Response, config config.Config) error {
+	_, err := fmt.Fprint(adapter.output, renderTerminal(response, adapter.options.Colour))
+	return err
+}
+
+// runCLI handles the text as a single command if it's provided, and
+// otherwise starts an interactive session that reads commands until it's
+// ended with exit or quit.
+func runCLI(text string, options cliOptions, input io.Reader, output io.Writer) error {
+	adapter := cliAdapter{options: options, output: output}
+	if text != "" {
+		_, response := handle(context.Background(), adapter, body{Body: text})
+		return adapter.PostResponse("", response, config.Config{})
+	}
+	fmt.Fprintln(output, "Send Igor a command, or use exit to quit.")
+	scanner := bufio.NewScanner(input)
+	for {
+		fmt.Fprint(output, "igor> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(output)
+			return scanner.Err()
+		}
+		text := strings.TrimSpace(scanner.Text())
+		switch text {
+		case "":
+			continue
+		case "exit", "quit":
+			return nil
+		}
+		_, response := handle(context.Background(), adapter, body{Body: text})
+		if err := adapter.PostResponse("", response, config.Config{}); err != nil {
+			return err
+		}
+	}
+}
+
+// cliUser returns the name of the user running Igor, which is the default
+// user name in CLI mode
+func cliUser() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	return "cli"
+}
+
+// isTerminal checks if the file is a terminal, so colours are only used
+// when they can be displayed
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiItalic = "\x1b[3m"
+)
+
+// ansiColours contains the colours for the named attachment colours
+var ansiColours = map[string]string{
+	slack.ResponseGood:    "\x1b[32m",
+	slack.ResponseWarning: "\x1b[33m",
+	slack.ResponseBad:     "\x1b[31m",
+}
+
+var (
+	labelledLinkRegex = regexp.MustCompile(`<([^<>|]+)\|([^<>]+)>`)
+	linkRegex         = regexp.MustCompile(`<([^<>|]+)>`)
+	boldRegex         = regexp.MustCompile(`\*([^*\n]+)\*`)
+	italicRegex       = regexp.MustCompile(`\b_([^_\n]+)_\b`)
+	codeRegex         = regexp.MustCompile("`([^`\n]+)`")
+)
+
+// renderTerminal renders the response as text. Attachments are indented
+// with a bar in their colour, followed by their fields and buttons.
+func renderTerminal(response slack.Response, colour bool) string {
+	style := func(code string, text string) string {
+		if !colour || code == "" || text == "" {
+			return text
+		}
+		return code + text + ansiReset
+	}
+	var output strings.Builder
+	if response.Text != "" {
+		output.WriteString(terminalText(response.Text, colour) + "\n")
+	}
+	for _, attachment := range response.Attachments {
+		bar := style(attachmentColour(attachment.Color), "▌") + " "
+		lines := []string{}
+		if attachment.PreText != "" {
+			output.WriteString(terminalText(attachment.PreText, colour) + "\n")
+		}
+		if attachment.AuthorName != "" {
+			lines = append(lines, style(ansiDim, attachment.AuthorName))
+		}
+		if attachment.Title != "" {
+			title := style(ansiBold, terminalText(attachment.Title, colour))
+			if attachment.TitleLink != "" {
+				title += " " + style(ansiDim, "("+attachment.TitleLink+")")
+			}
+			lines = append(lines, title)
+		}
+		if attachment.Text != "" {
+			text := strings.TrimRight(terminalText(attachment.Text, colour), "\n")
+			lines = append(lines, strings.Split(text, "\n")...)
+		}
+		for _, field := range attachment.Fields {
+			lines = append(lines, style(ansiBold, terminalText(field.Title, colour)+":")+" "+terminalText(field.Value, colour))
+		}
+		if attachment.ImageURL != "" {
+			lines = append(lines, style(ansiDim, "Image: "+attachment.ImageURL))
+		}
+		if attachment.ThumbURL != "" {
+			lines = append(lines, style(ansiDim, "Thumbnail: "+attachment.ThumbURL))
+		}
+		if len(attachment.Actions) != 0 {
+			buttons := []string{}
+			for _, action := range attachment.Actions {
+				buttons = append(buttons, "["+action.Text+"]")
+			}
+			lines = append(lines, style(ansiDim, strings.Join(buttons, " ")))
+		}
+		for _, line := range lines {
+			output.WriteString(bar + line + "\n")
+		}
+	}
+	if output.Len() == 0 {
+		output.WriteString(style(ansiDim, "(no response)") + "\n")
+	}
+	return output.String()
+}
+
+// terminalText converts Slack's markup into text for the terminal, with
+// bold, italic, and code shown as such when colours are enabled
+func terminalText(text string, colour bool) string {
+	format := func(regex *regexp.Regexp, code string) {
+		replacement := "$1"
+		if colour {
+			replacement = code + "$1" + ansiReset
+		}
+		text = regex.ReplaceAllString(text, replacement)
+	}
+	text = labelledLinkRegex.ReplaceAllString(text, "$2 ($1)")
+	text = linkRegex.ReplaceAllString(text, "$1")
+	format(boldRegex, ansiBold)
+	format(italicRegex, ansiItalic)
+	format(codeRegex, ansiDim)
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&").Replace(text)
+}
+
+// attachmentColour returns the ANSI code for an attachment colour, which is
+// either one of the named colours or a hex colour
+func attachmentColour(colour string) string {
+	if code, ok := ansiColours[colour]; ok {
+		return code
+	}
+	hex := strings.TrimPrefix(colour, "#")
+	if len(hex) != 6 {
+		return ""
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", value>>16, value>>8&0xff, value&0xff)
+}
@@ -6,21 +6,60 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 var configHolder Config
+var configLoaded bool
 
 // Config contains general configuration details
 type Config struct {
-	Kms             bool
-	Token           string
-	DefaultLanguage string
-	Blacklist       []string
-	Whitelist       []string
-	Languages       map[string]languageConfig
-	LanguageDir     string
+	Kms              bool
+	Token            string
+	SigningSecret    string
+	BotToken         string
+	Platform         string
+	MattermostToken  string
+	DiscordPublicKey string
+	TeamsSecret      string
+	TelegramToken    string
+	TelegramSecret   string
+	TelegramPath     string
+	TelegramAPIURL   string
+	DefaultLanguage  string
+	Blacklist        []string
+	Whitelist        []string
+	Channels         map[string]ChannelPolicy
+	Restrictions     map[string][]string
+	Roles            map[string]Role
+	Permissions      map[string]string
+	Async            []string
+	Priority         []string
+	Timeouts         map[string]string
+	RateLimits       map[string]RateLimit
+	RateLimitTable   string
+	Server           ServerConfig
+	LogFormat        string
+	Audit            AuditConfig
+	Teams            map[string]Team
+	BlockKit         bool
+	Languages        map[string]languageConfig
+	LanguageDir      string
+}
+
+// ServerConfig contains the settings for running Igor as a server. The
+// timeouts are durations like "10s".
+type ServerConfig struct {
+	Address         string
+	TLSCert         string
+	TLSKey          string
+	ReadTimeout     string
+	WriteTimeout    string
+	IdleTimeout     string
+	ShutdownTimeout string
+	MaxBodySize     int64
 }
 
 type languageConfig struct {
@@ -41,13 +80,111 @@ type LanguagePluginCommandDetails struct {
 	Texts       map[string]string
 }
 
+// RunsAsync checks if the plugin is configured to run in the background and
+// respond through the response_url
+func (config Config) RunsAsync(plugin string) bool {
+	for _, name := range config.Async {
+		if name == plugin {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultPluginTimeout is the time a plugin gets to handle a request, unless
+// configured otherwise
+const DefaultPluginTimeout = 10 * time.Second
+
+// PluginTimeout returns the time the plugin gets to handle a request. This
+// is the plugin's configured timeout, or the configured default.
+func (config Config) PluginTimeout(plugin string) time.Duration {
+	for _, name := range []string{plugin, "default"} {
+		if value, ok := config.Timeouts[name]; ok {
+			if timeout, err := time.ParseDuration(value); err == nil && timeout > 0 {
+				return timeout
+			}
+		}
+	}
+	return DefaultPluginTimeout
+}
+
+// AuditConfig contains where the audit records of requests are written. File
+// is a file the records are appended to as JSON lines, Stdout writes them to
+// the standard output, and DynamoDB is the name of a table to store them in.
+type AuditConfig struct {
+	File     string
+	Stdout   bool
+	DynamoDB string
+}
+
+// ChannelPolicy contains the rules for a channel, which is found by its ID
+// or name. The whitelist and blacklist contain plugins, like "tumblr", or
+// commands of a plugin, like "remember.forget". When Ephemeral is set,
+// responses in the channel are only shown to the user who asked.
+type ChannelPolicy struct {
+	Whitelist []string
+	Blacklist []string
+	Ephemeral bool
+}
+
+// Role gives its members permissions. Members are users, by their ID or
+// name, and Groups are Slack user groups by their ID. The permission "*"
+// grants every permission.
+type Role struct {
+	Members     []string
+	Groups      []string
+	Permissions []string
+}
+
+// Grants checks if the role gives its members the permission
+func (role Role) Grants(permission string) bool {
+	for _, granted := range role.Permissions {
+		if granted == permission || granted == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimit is the number of requests a single user, and everyone in a
+// channel together, can send to a plugin within the period. A limit of 0
+// means there's no limit. The period is a duration like "1m".
+type RateLimit struct {
+	User    int
+	Channel int
+	Period  string
+}
+
+// DefaultRateLimitPeriod is the period of a rate limit without one
+const DefaultRateLimitPeriod = time.Minute
+
+// PluginRateLimit returns the plugin's rate limit, which is the plugin's
+// configured limit or the configured default. It returns false if neither
+// is configured.
+func (config Config) PluginRateLimit(plugin string) (RateLimit, bool) {
+	for _, name := range []string{plugin, "default"} {
+		if limit, ok := config.RateLimits[name]; ok {
+			return limit, true
+		}
+	}
+	return RateLimit{}, false
+}
+
+// Window returns the period of the rate limit
+func (limit RateLimit) Window() time.Duration {
+	if window, err := time.ParseDuration(limit.Period); err == nil && window > 0 {
+		return window
+	}
+	return DefaultRateLimitPeriod
+}
+
 var configFile []byte
 var jsonConfig = true
 var fallbackLanguage = "english.yml"
 
 // GeneralConfig reads the configuration file and parses its general information
 func GeneralConfig() (Config, error) {
-	if configHolder.Token == "" {
+	if !configLoaded {
 		config := Config{}
 		err := ParseConfig(&config)
 		if err != nil {
@@ -57,6 +194,36 @@ func GeneralConfig() (Config, error) {
 		if err != nil {
 			return config, err
 		}
+		config.SigningSecret, err = decryptValue(config.Kms, config.SigningSecret)
+		if err != nil {
+			return config, err
+		}
+		config.BotToken, err = decryptValue(config.Kms, config.BotToken)
+		if err != nil {
+			return config, err
+		}
+		config.MattermostToken, err = decryptValue(config.Kms, config.MattermostToken)
+		if err != nil {
+			return config, err
+		}
+		config.TeamsSecret, err = decryptValue(config.Kms, config.TeamsSecret)
+		if err != nil {
+			return config, err
+		}
+		config.TelegramToken, err = decryptValue(config.Kms, config.TelegramToken)
+		if err != nil {
+			return config, err
+		}
+		config.TelegramSecret, err = decryptValue(config.Kms, config.TelegramSecret)
+		if err != nil {
+			return config, err
+		}
+		if err = decryptTeams(&config); err != nil {
+			return config, err
+		}
+		if config.TelegramAPIURL == "" {
+			config.TelegramAPIURL = "https://api.telegram.org"
+		}
 		if config.LanguageDir == "" {
 			config.LanguageDir = "language"
 		}
@@ -81,6 +248,7 @@ func GeneralConfig() (Config, error) {
 			config.DefaultLanguage = strings.Replace(config.DefaultLanguage, ".yml", "", -1) + ".yml"
 		}
 		configHolder = config
+		configLoaded = true
 	}
 	return configHolder, nil
 }
@@ -107,6 +275,12 @@ func getConfigFile() ([]byte, error) {
 	return configFile, nil
 }
 
+// Contents returns the configuration as it's read, before it's parsed. It
+// can be compared to find out if the configuration changed.
+func Contents() ([]byte, error) {
+	return getConfigFile()
+}
+
 // ParseConfig parses the config file and unmarshals it into the
 // provided interface
 func ParseConfig(values interface{}) error {
@@ -15,7 +15,7 @@ func DecryptString(toDecrypt string) (string, error) {
 }
 
 func decryptValue(useKms bool, toDecrypt string) (string, error) {
-	if !useKms {
+	if !useKms || toDecrypt == "" {
 		return toDecrypt, nil
 	}
 	sess, err := session.NewSession()
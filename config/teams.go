@@ -0,0 +1,188 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Team contains the settings for a single workspace, found in the teams
+// section by the workspace's team_id. Settings that aren't set use the
+// global value. Next to these, a team can override the settings of plugins,
+// which are picked up with ParseTeamConfig.
+type Team struct {
+	Token           string
+	SigningSecret   string
+	BotToken        string
+	DefaultLanguage string
+	Whitelist       []string
+	Blacklist       []string
+	Roles           map[string]Role
+	Permissions     map[string]string
+}
+
+// TeamConfig returns the configuration for requests from the team, which is
+// the general configuration with the team's settings layered over it
+func TeamConfig(teamID string) (Config, error) {
+	config, err := GeneralConfig()
+	if err != nil {
+		return config, err
+	}
+	return config.ForTeam(teamID), nil
+}
+
+// ForTeam applies the settings of the team to the configuration. If the team
+// isn't configured the configuration is returned as is.
+func (config Config) ForTeam(teamID string) Config {
+	team, ok := config.Teams[teamID]
+	if !ok || teamID == "" {
+		return config
+	}
+	if team.Token != "" {
+		config.Token = team.Token
+	}
+	if team.SigningSecret != "" {
+		config.SigningSecret = team.SigningSecret
+	}
+	if team.BotToken != "" {
+		config.BotToken = team.BotToken
+	}
+	if team.DefaultLanguage != "" {
+		config.DefaultLanguage = team.DefaultLanguage
+	}
+	if team.Whitelist != nil {
+		config.Whitelist = team.Whitelist
+	}
+	if team.Blacklist != nil {
+		config.Blacklist = team.Blacklist
+	}
+	// User IDs and groups are different in every workspace, so a team's
+	// roles replace the global ones
+	if team.Roles != nil {
+		config.Roles = team.Roles
+	}
+	if team.Permissions != nil {
+		config.Permissions = team.Permissions
+	}
+	return config
+}
+
+// decryptTeams decrypts the secrets of every team, and normalises their
+// default language like the global one
+func decryptTeams(config *Config) error {
+	for teamID, team := range config.Teams {
+		var err error
+		if team.Token, err = decryptValue(config.Kms, team.Token); err != nil {
+			return err
+		}
+		if team.SigningSecret, err = decryptValue(config.Kms, team.SigningSecret); err != nil {
+			return err
+		}
+		if team.BotToken, err = decryptValue(config.Kms, team.BotToken); err != nil {
+			return err
+		}
+		if team.DefaultLanguage != "" {
+			team.DefaultLanguage = strings.Replace(team.DefaultLanguage, ".yml", "", -1) + ".yml"
+		}
+		config.Teams[teamID] = team
+	}
+	return nil
+}
+
+// ParseTeamConfig parses the configuration like ParseConfig, with the
+// sections of the team layered over the global sections. A team's weather
+// section only needs to contain the settings that are different, like its
+// default city.
+func ParseTeamConfig(teamID string, values interface{}) error {
+	var settings interface{}
+	if err := ParseConfig(&settings); err != nil {
+		return err
+	}
+	team := findSetting(findSetting(settings, "teams"), teamID)
+	if teamID == "" || team == nil {
+		return ParseConfig(values)
+	}
+	merged := mergeSettings(settings, team)
+	if jsonConfig {
+		encoded, err := json.Marshal(merged)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(encoded, values)
+	}
+	encoded, err := yaml.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(encoded, values)
+}
+
+// findSetting retrieves a value from a parsed section of the configuration.
+// Names are compared case insensitively, like when the configuration is
+// parsed.
+func findSetting(section interface{}, name string) interface{} {
+	switch section := section.(type) {
+	case map[string]interface{}:
+		for key, value := range section {
+			if strings.EqualFold(key, name) {
+				return value
+			}
+		}
+	case map[interface{}]interface{}:
+		for key, value := range section {
+			if strings.EqualFold(fmt.Sprint(key), name) {
+				return value
+			}
+		}
+	}
+	return nil
+}
+
+// mergeSettings layers the overrides over the base settings. Sections that
+// are in both are merged, anything else in the overrides replaces the base
+// value.
+func mergeSettings(base interface{}, overrides interface{}) interface{} {
+	switch baseSection := base.(type) {
+	case map[string]interface{}:
+		overrideSection, ok := overrides.(map[string]interface{})
+		if !ok {
+			return overrides
+		}
+		merged := make(map[string]interface{})
+		for key, value := range baseSection {
+			merged[key] = value
+		}
+		for key, value := range overrideSection {
+			for baseKey := range merged {
+				if strings.EqualFold(baseKey, key) {
+					value = mergeSettings(merged[baseKey], value)
+					delete(merged, baseKey)
+				}
+			}
+			merged[key] = value
+		}
+		return merged
+	case map[interface{}]interface{}:
+		overrideSection, ok := overrides.(map[interface{}]interface{})
+		if !ok {
+			return overrides
+		}
+		merged := make(map[interface{}]interface{})
+		for key, value := range baseSection {
+			merged[key] = value
+		}
+		for key, value := range overrideSection {
+			for baseKey := range merged {
+				if strings.EqualFold(fmt.Sprint(baseKey), fmt.Sprint(key)) {
+					value = mergeSettings(merged[baseKey], value)
+					delete(merged, baseKey)
+				}
+			}
+			merged[key] = value
+		}
+		return merged
+	}
+	return overrides
+}
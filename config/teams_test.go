@@ -0,0 +1,64 @@
+package config_test
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/config"
+)
+
+func TestForTeam(t *testing.T) {
+	general := config.Config{
+		Token:           "globaltoken",
+		DefaultLanguage: "english.yml",
+		Blacklist:       []string{"remember"},
+		Teams: map[string]config.Team{
+			"T0001": {SigningSecret: "teamsecret", DefaultLanguage: "dutch.yml", Whitelist: []string{"weather"}},
+		},
+	}
+	team := general.ForTeam("T0001")
+	if team.SigningSecret != "teamsecret" || team.DefaultLanguage != "dutch.yml" {
+		t.Errorf("Expected the team's secret and language, actual %q and %q", team.SigningSecret, team.DefaultLanguage)
+	}
+	if team.Token != "globaltoken" || !reflect.DeepEqual(team.Blacklist, []string{"remember"}) {
+		t.Errorf("Expected the global settings the team doesn't override, actual %q and %v", team.Token, team.Blacklist)
+	}
+	if !reflect.DeepEqual(team.Whitelist, []string{"weather"}) {
+		t.Errorf("Expected the team's whitelist, actual %v", team.Whitelist)
+	}
+	if other := general.ForTeam("T0002"); other.SigningSecret != "" || other.DefaultLanguage != "english.yml" {
+		t.Errorf("Expected the global settings for other teams, actual %v", other)
+	}
+}
+
+func TestParseTeamConfig(t *testing.T) {
+	err := os.Setenv("IGOR_CONFIG", `{"weather": {"apitoken": "abc", "defaultcity": "Melbourne,au"},
+		"teams": {"T0001": {"weather": {"defaultcity": "Amsterdam,nl"}}}}`)
+	if err != nil {
+		t.Fatal("Problem setting environment variable")
+	}
+	defer os.Unsetenv("IGOR_CONFIG")
+	var parseTests = []struct {
+		team string
+		city string
+	}{
+		{"T0001", "Amsterdam,nl"},
+		{"T0002", "Melbourne,au"},
+		{"", "Melbourne,au"},
+	}
+	for _, tt := range parseTests {
+		values := struct {
+			Weather struct {
+				APIToken    string
+				DefaultCity string
+			}
+		}{}
+		if err := config.ParseTeamConfig(tt.team, &values); err != nil {
+			t.Fatal(err)
+		}
+		if values.Weather.DefaultCity != tt.city || values.Weather.APIToken != "abc" {
+			t.Errorf("ParseTeamConfig(%v): expected %s with the global token, actual %v", tt.team, tt.city, values.Weather)
+		}
+	}
+}
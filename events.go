@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/logging"
+	"github.com/ArjenSchwarz/igor/metrics"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// eventReply contains the details needed to reply to an event through the
+// Web API, as events don't have a response_url
+type eventReply struct {
+	Channel       string `json:"channel"`
+	User          string `json:"user"`
+	ThreadTS      string `json:"thread_ts"`
+	DirectMessage bool   `json:"direct_message"`
+}
+
+// seenEvents keeps track of the events that were already handled by this
+// instance, in case Slack sends an event again without marking it as a retry
+var seenEvents = struct {
+	sync.Mutex
+	ids map[string]time.Time
+}{ids: make(map[string]time.Time)}
+
+// eventExpiry is how long handled events are remembered. Slack retries
+// within minutes, so this leaves plenty of room.
+var eventExpiry = time.Hour
+
+// handleEvents is the endpoint for the Slack Events API. It answers the
+// url_verification challenge, and handles mentions and direct messages the
+// same way as slash commands.
+func handleEvents(ctx context.Context, body body) interface{} {
+	callback, err := slack.LoadEventCallback(body.Body, body.Headers)
+	if err != nil {
+		metrics.Requests.Inc("slack", metrics.OutcomeValidationFailure)
+		return slack.ValidationErrorResponse()
+	}
+	request := callback.Request()
+	ctx = requestContext(ctx, &request)
+	config, err := config.TeamConfig(request.TeamID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to load the configuration", err)
+		return struct{}{}
+	}
+	if !request.Validate(config) {
+		metrics.Requests.Inc(request.Platform, metrics.OutcomeValidationFailure)
+		return slack.ValidationErrorResponse()
+	}
+	if callback.IsURLVerification() {
+		return map[string]string{"challenge": callback.Challenge}
+	}
+	// Retries are acknowledged without handling them again, the first
+	// delivery is already being handled, possibly by another instance
+	if callback.Retry {
+		logging.FromContext(ctx).With(logging.Fields{"event_id": callback.EventID}).Info("Skipping a retried event")
+		return struct{}{}
+	}
+	if !callback.IsCommand() || isDuplicateEvent(callback.EventID) {
+		return struct{}{}
+	}
+	reply := eventReply{
+		Channel:       callback.Event.Channel,
+		User:          callback.Event.User,
+		ThreadTS:      callback.Event.ThreadTS,
+		DirectMessage: callback.IsDirectMessage(),
+	}
+	job := asyncJob{Request: request, Event: &reply}
+	// Slack expects events to be acknowledged within 3 seconds
+	if dispatchAsync == nil || dispatchAsync(job) != nil {
+		runAsync(ctx, job)
+	}
+	return struct{}{}
+}
+
+// isDuplicateEvent checks whether the event was handled before, and marks it
+// as handled
+func isDuplicateEvent(eventID string) bool {
+	seenEvents.Lock()
+	defer seenEvents.Unlock()
+	now := time.Now()
+	for id, seen := range seenEvents.ids {
+		if now.Sub(seen) > eventExpiry {
+			delete(seenEvents.ids, id)
+		}
+	}
+	if _, ok := seenEvents.ids[eventID]; ok {
+		return true
+	}
+	seenEvents.ids[eventID] = now
+	return false
+}
+
+// replyToEvent sends the response to the channel the event came from. In
+// channels, responses that aren't public are only shown to the user.
+func replyToEvent(reply eventReply, response slack.Response, config config.Config) error {
+	message := slack.Message{
+		Response: response,
+		Channel:  reply.Channel,
+		ThreadTS: reply.ThreadTS,
+	}
+	if reply.DirectMessage || response.IsPublic() {
+		return slack.PostMessage(config.BotToken, message)
+	}
+	message.User = reply.User
+	return slack.PostEphemeral(config.BotToken, message)
+}
@@ -1,62 +1,189 @@
 package main
 
 import (
+	"context"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/audit"
 	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/logging"
+	"github.com/ArjenSchwarz/igor/metrics"
+	"github.com/ArjenSchwarz/igor/platforms"
 	"github.com/ArjenSchwarz/igor/plugins"
 	"github.com/ArjenSchwarz/igor/slack"
 )
 
-// handle is the main handling function. It parses the received message and
-// ensures that a response is collected.
-// It also ensures that the resulting response is properly escaped
-func handle(body body) slack.Response {
-	request := slack.LoadRequestFromQuery(body.Body)
-	config, err := config.GeneralConfig()
+// rateLimiter counts the requests for the rate limits. It depends on the mode
+// Igor runs in, and when it's nil there are no limits.
+var rateLimiter plugins.Limiter
+
+// handle is the main handling function. It parses the received message using
+// the adapter for the platform it came from, and ensures that a response is
+// collected. The parsed request is returned as well, as some platforms need
+// its details to deliver the response. The request is validated and handled
+// with the configuration of the team it came from.
+// Rendering the response for the platform, including escaping, is left to
+// the adapter.
+// Requests that are answered directly or in the background are counted and
+// audited by determineResponse, the others are counted and audited here.
+func handle(ctx context.Context, adapter platforms.Adapter, body body) (slack.Request, slack.Response) {
+	request, err := adapter.ParseRequest(platforms.Incoming{Body: body.Body, Headers: body.Headers})
+	ctx = requestContext(ctx, &request)
+	logger := logging.FromContext(ctx).With(logging.Fields{"platform": adapter.Name()})
+	record := audit.NewRecord(request)
+	record.Platform = adapter.Name()
 	if err != nil {
-		response := slack.SomethingWrongResponse(request)
-		response.Escape()
-		return response
+		logger.Error("Failed to parse the request", err)
+		metrics.Requests.Inc(adapter.Name(), metrics.OutcomeValidationFailure)
+		record.Finish(metrics.OutcomeValidationFailure)
+		audit.Log(ctx, record)
+		return request, slack.ValidationErrorResponse()
+	}
+	config, err := config.TeamConfig(request.TeamID)
+	if err != nil {
+		logger.Error("Failed to load the configuration", err)
+		metrics.Requests.Inc(adapter.Name(), metrics.OutcomeSomethingWrong)
+		record.Finish(metrics.OutcomeSomethingWrong)
+		audit.Log(ctx, record)
+		return request, slack.SomethingWrongResponse(request)
 	}
 	response := slack.Response{}
-	if !request.Validate(config) {
+	if !adapter.Validate(request, config) {
+		logger.Info("Request failed validation")
+		metrics.Requests.Inc(adapter.Name(), metrics.OutcomeValidationFailure)
+		record.Finish(metrics.OutcomeValidationFailure)
+		audit.Log(ctx, record)
 		response = slack.ValidationErrorResponse()
+	} else if delay(ctx, request, config) {
+		response = slack.DelayedResponse()
 	} else {
-		response = determineResponse(request, config)
+		response = determineResponse(ctx, request, config)
 	}
-	response.Escape()
-	return response
+	return request, response
 }
 
-// determineResponse parses the responses from a list of plugin triggers
-func determineResponse(request slack.Request, config config.Config) slack.Response {
+// determineResponse parses the responses from a list of plugin triggers.
+// Every plugin runs with its own deadline, and a plugin that panics is
+// treated like one that returned an error. The outcome of the request and
+// the work of the plugins are recorded in the metrics, and logged with the
+// ID of the request. Every request is written to the audit log with the
+// plugin and command that decided its outcome.
+func determineResponse(ctx context.Context, request slack.Request, config config.Config) slack.Response {
+	ctx = requestContext(ctx, &request)
+	logger := logging.FromContext(ctx)
+	record := audit.NewRecord(request)
+	finish := func(outcome string, route *plugins.Route) {
+		metrics.Requests.Inc(request.Platform, outcome)
+		if route != nil {
+			record.Plugin, record.Command, record.Language = route.Plugin, route.Command, route.Language
+		}
+		record.Finish(outcome)
+		audit.Log(ctx, record)
+	}
 	forcePublic := false
-	if request.Text[0] == '!' {
+	if request.Text != "" && request.Text[0] == '!' {
 		forcePublic = true
 		request.Text = request.Text[1:]
 	}
-	pluginlist := plugins.GetPlugins(request, config)
-	hasError := false
-	//TODO clean this up
-	for _, value := range pluginlist {
-		response, err := value.Work()
+	var failedRoute *plugins.Route
+	var usageError *plugins.UsageError
+	var usageRoute plugins.Route
+	limits := plugins.NewRequestLimits(rateLimiter, request, config)
+	// Plugins are tried in order of how well they match, falling back to the
+	// next one if a plugin can't handle the request after all
+	for _, match := range plugins.MatchingPlugins(request, config) {
+		name := match.Route.Plugin
+		pluginLogger := logger.With(logging.Fields{
+			"plugin":   name,
+			"command":  match.Route.Command,
+			"language": match.Route.Language,
+		})
+		if err, ok := plugins.CheckPermission(ctx, request, match, config).(*plugins.PermissionError); ok {
+			pluginLogger.Info("User doesn't have the permission for the command")
+			finish(metrics.OutcomeForbidden, &match.Route)
+			return err.Response()
+		}
+		// Every plugin that runs is charged once for the request, so
+		// falling back doesn't get around the limit of a plugin
+		if err := limits.Check(ctx, match.Route); err != nil {
+			if limitErr, ok := err.(*plugins.RateLimitError); ok {
+				pluginLogger.Info("Rate limit reached")
+				finish(metrics.OutcomeRateLimited, &match.Route)
+				return limitErr.Response()
+			}
+			// Requests aren't blocked when the limits can't be checked
+			pluginLogger.Error("Failed to check the rate limit", err)
+		}
+		start := time.Now()
+		response, err := plugins.Run(logging.NewContext(ctx, pluginLogger), name, config.PluginTimeout(name), match.Plugin.Work)
+		latency := time.Since(start)
+		metrics.PluginDuration.Observe(latency, name)
+		pluginLogger = pluginLogger.With(logging.Fields{"latency": latency})
 		if err == nil {
+			pluginLogger.Info("Plugin answered the request")
+			metrics.PluginMatches.Inc(name)
+			finish(metrics.OutcomeAnswered, &match.Route)
 			if forcePublic {
 				response.SetPublic()
 			}
+			// The channel's policy takes precedence over the user and plugin
+			if plugins.ForcedEphemeral(request, config) {
+				response.SetEphemeral()
+			}
 			return response
 		}
-		switch err.(type) {
+		switch err := err.(type) {
 		case *plugins.NoMatchError:
+			pluginLogger.Info("Plugin couldn't handle the request")
+		case *plugins.UsageError:
+			pluginLogger.Info("Plugin explained its usage")
+			// The request was meant for this plugin, explain how to use
+			// it unless another plugin can handle the request
+			if usageError == nil {
+				usageError = err
+				usageRoute = match.Route
+			}
 		default:
 			// Something actually went wrong with one of the plugins,
 			// return that something went wrong if nothing matches
 			// Don't send the actual message though
-			hasError = true
+			pluginLogger.Error("Plugin failed", err)
+			metrics.PluginErrors.Inc(name)
+			if failedRoute == nil {
+				route := match.Route
+				failedRoute = &route
+			}
 		}
 	}
-	if hasError {
+	if usageError != nil {
+		finish(metrics.OutcomeUsage, &usageRoute)
+		return usageError.Response()
+	}
+	if failedRoute != nil {
+		finish(metrics.OutcomeSomethingWrong, failedRoute)
 		return slack.SomethingWrongResponse(request)
 	}
 
+	logger.Info("No plugin could handle the request")
+	finish(metrics.OutcomeNothingFound, nil)
 	return slack.NothingFoundResponse(request)
 }
+
+// requestContext gives the request an ID if it doesn't have one yet, and
+// returns a context with a logger that adds the ID and the request's origin
+// to its lines. Secrets like the token and response_url are left out.
+func requestContext(ctx context.Context, request *slack.Request) context.Context {
+	if request.RequestID == "" {
+		request.RequestID = logging.NewRequestID()
+	}
+	logger := logging.FromContext(ctx).With(logging.Fields{
+		"request_id": request.RequestID,
+		"team_id":    request.TeamID,
+		"channel_id": request.ChannelID,
+		"user_id":    request.UserID,
+	})
+	if request.Platform != "" {
+		logger = logger.With(logging.Fields{"platform": request.Platform})
+	}
+	return logging.NewContext(ctx, logger)
+}
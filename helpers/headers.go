@@ -0,0 +1,21 @@
+package helpers
+
+import (
+	"strings"
+)
+
+// GetHeader retrieves the value of a header from a map of headers.
+// HTTP header names are case insensitive, and depending on how the request
+// came in (API Gateway, the built-in server) the casing differs, so the
+// lookup ignores case.
+func GetHeader(headers map[string]string, name string) string {
+	if value, ok := headers[name]; ok {
+		return value
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
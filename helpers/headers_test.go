@@ -0,0 +1,30 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/helpers"
+)
+
+func TestGetHeader(t *testing.T) {
+	headers := map[string]string{
+		"X-Slack-Signature":         "v0=abc",
+		"x-slack-request-timestamp": "1531420618",
+	}
+	var headerTests = []struct {
+		input    string
+		expected string
+	}{
+		{"X-Slack-Signature", "v0=abc"},
+		{"x-slack-signature", "v0=abc"},
+		{"X-Slack-Request-Timestamp", "1531420618"},
+		{"X-Missing", ""},
+	}
+
+	for _, tt := range headerTests {
+		actual := helpers.GetHeader(headers, tt.input)
+		if actual != tt.expected {
+			t.Errorf("GetHeader(%v): expected %v, actual %v", tt.input, tt.expected, actual)
+		}
+	}
+}
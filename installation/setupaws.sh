@@ -31,39 +31,27 @@ aws apigateway create-rest-api --name "${APINAME}" --description "Api for ${NAME
 APIID=$(aws apigateway get-rest-apis --query "items[?name==\`${APINAME}\`].id" --output text --region ${REGION})
 PARENTRESOURCEID=$(aws apigateway get-resources --rest-api-id ${APIID} --query 'items[?path==`/`].id' --output text --region ${REGION})
 
-# Add the resource
+# Add the resources. The slash command is handled at /igor, other Slack
+# endpoints (like events) are sub resources of that.
 aws apigateway create-resource --rest-api-id ${APIID} --parent-id ${PARENTRESOURCEID} --path-part igor --region ${REGION}
 RESOURCEID=$(aws apigateway get-resources --rest-api-id ${APIID} --query 'items[?path==`/igor`].id' --output text --region ${REGION})
+aws apigateway create-resource --rest-api-id ${APIID} --parent-id ${RESOURCEID} --path-part "{endpoint}" --region ${REGION}
+ENDPOINTRESOURCEID=$(aws apigateway get-resources --rest-api-id ${APIID} --query 'items[?path==`/igor/{endpoint}`].id' --output text --region ${REGION})
 
-# Add the POST method
-aws apigateway put-method --rest-api-id ${APIID} --resource-id ${RESOURCEID} --http-method POST --authorization-type NONE --region ${REGION}
+# Add the POST method with a proxy integration, so the headers and the raw
+# body are available to verify the request signature
+for ID in ${RESOURCEID} ${ENDPOINTRESOURCEID}
+do
+    aws apigateway put-method --rest-api-id ${APIID} --resource-id ${ID} --http-method POST --authorization-type NONE --region ${REGION}
 
-# Method request config
-aws apigateway put-integration --rest-api-id ${APIID} \
---resource-id ${RESOURCEID} \
---http-method POST \
---type AWS \
---integration-http-method POST \
---uri arn:aws:apigateway:${REGION}:lambda:path/2015-03-31/functions/${LAMBDAARN}/invocations \
---request-templates '{"application/x-www-form-urlencoded":"{\"body\": $input.json(\"$\")}"}' \
---region ${REGION}
-
-# Method response config
-aws apigateway put-method-response \
---rest-api-id ${APIID} \
---resource-id ${RESOURCEID} \
---http-method POST \
---status-code 200 \
---response-models "{}" \
---region ${REGION}
-
-aws apigateway put-integration-response \
---rest-api-id ${APIID} \
---resource-id ${RESOURCEID} \
---http-method POST \
---status-code 200 \
---selection-pattern ".*" \
---region ${REGION}
+    aws apigateway put-integration --rest-api-id ${APIID} \
+    --resource-id ${ID} \
+    --http-method POST \
+    --type AWS_PROXY \
+    --integration-http-method POST \
+    --uri arn:aws:apigateway:${REGION}:lambda:path/2015-03-31/functions/${LAMBDAARN}/invocations \
+    --region ${REGION}
+done
 
 # Deploy Gateway
 aws apigateway create-deployment \
@@ -78,7 +66,7 @@ aws lambda add-permission \
 --statement-id apigateway-igor-test-2 \
 --action lambda:InvokeFunction \
 --principal apigateway.amazonaws.com \
---source-arn "${APIARN}/*/POST/igor" \
+--source-arn "${APIARN}/*/POST/igor*" \
 --region ${REGION}
 
 aws lambda add-permission \
@@ -86,8 +74,12 @@ aws lambda add-permission \
 --statement-id apigateway-igor-prod-2 \
 --action lambda:InvokeFunction \
 --principal apigateway.amazonaws.com \
---source-arn "${APIARN}/prod/POST/igor" \
+--source-arn "${APIARN}/prod/POST/igor*" \
 --region ${REGION}
 
-echo "The url you have to use in your Slack settings is:
-https://${APIID}.execute-api.${REGION}.amazonaws.com/prod/igor"
+echo "The url you have to use for the slash command in your Slack settings is:
+https://${APIID}.execute-api.${REGION}.amazonaws.com/prod/igor
+The url for the Events API is:
+https://${APIID}.execute-api.${REGION}.amazonaws.com/prod/igor/events
+The url for Interactivity is:
+https://${APIID}.execute-api.${REGION}.amazonaws.com/prod/igor/interactions"
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+
+	"github.com/ArjenSchwarz/igor/audit"
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/logging"
+	"github.com/ArjenSchwarz/igor/metrics"
+	"github.com/ArjenSchwarz/igor/plugins"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// actionJob contains the details needed to pass an action on to the plugin
+// that owns it
+type actionJob struct {
+	Plugin string       `json:"plugin"`
+	Action slack.Action `json:"action"`
+}
+
+// handleInteractions is the endpoint for Slack interactive components. The
+// action is acknowledged immediately, and the plugin's response is sent to
+// the response_url.
+func handleInteractions(ctx context.Context, body body) interface{} {
+	interaction, err := slack.LoadInteraction(body.Body, body.Headers)
+	if err != nil {
+		return slack.ValidationErrorResponse()
+	}
+	request := interaction.Request()
+	ctx = requestContext(ctx, &request)
+	config, err := config.TeamConfig(request.TeamID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to load the configuration", err)
+		return nil
+	}
+	if !request.Validate(config) {
+		return slack.ValidationErrorResponse()
+	}
+	job := asyncJob{
+		Request: request,
+		Action:  &actionJob{Plugin: interaction.CallbackID, Action: interaction.Action},
+	}
+	if dispatchAsync == nil || dispatchAsync(job) != nil {
+		runAsync(ctx, job)
+	}
+	// An empty response leaves the original message unchanged
+	return nil
+}
+
+// determineActionResponse passes the action on to the plugin that owns it.
+// Actions count towards the rate limit of the plugin like its commands. They
+// can change things, like the confirmation for forgetting an image, so
+// they're written to the audit log with the action as the command.
+func determineActionResponse(ctx context.Context, request slack.Request, job actionJob, config config.Config) slack.Response {
+	record := audit.NewRecord(request)
+	record.Text = job.Action.Value
+	record.Plugin = job.Plugin
+	record.Command = job.Action.Name
+	plugin, route, ok := plugins.GetActionPlugin(request, config, job.Plugin, job.Action)
+	if !ok {
+		record.Finish(metrics.OutcomeNothingFound)
+		audit.Log(ctx, record)
+		return slack.NothingFoundResponse(request)
+	}
+	if err := plugins.CheckRateLimit(ctx, rateLimiter, request, route, config); err != nil {
+		if limitErr, ok := err.(*plugins.RateLimitError); ok {
+			logging.FromContext(ctx).With(logging.Fields{"plugin": job.Plugin, "action": job.Action.Name}).Info("Rate limit reached")
+			record.Finish(metrics.OutcomeRateLimited)
+			audit.Log(ctx, record)
+			return limitErr.Response()
+		}
+		// Actions aren't blocked when the limits can't be checked
+		logging.FromContext(ctx).Error("Failed to check the rate limit", err)
+	}
+	response, err := plugins.Run(ctx, job.Plugin, config.PluginTimeout(job.Plugin), func(ctx context.Context) (slack.Response, error) {
+		return plugin.HandleAction(ctx, job.Action)
+	})
+	if permissionErr, ok := err.(*plugins.PermissionError); ok {
+		logging.FromContext(ctx).With(logging.Fields{"plugin": job.Plugin, "action": job.Action.Name}).Info("User doesn't have the permission for the action")
+		record.Finish(metrics.OutcomeForbidden)
+		audit.Log(ctx, record)
+		return permissionErr.Response()
+	}
+	if err != nil {
+		logging.FromContext(ctx).With(logging.Fields{"plugin": job.Plugin, "action": job.Action.Name}).Error("Action failed", err)
+		record.Finish(metrics.OutcomeSomethingWrong)
+		audit.Log(ctx, record)
+		return slack.SomethingWrongResponse(request)
+	}
+	record.Finish(metrics.OutcomeAnswered)
+	audit.Log(ctx, record)
+	return response
+}
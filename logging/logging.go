@@ -0,0 +1,151 @@
+// Package logging provides Igor's structured logger. Every line has a message
+// and fields, like the ID of the request it belongs to, and is written as
+// text or as JSON. Secrets are redacted before anything is written.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fields are the details added to a log line
+type Fields map[string]interface{}
+
+// Logger writes log lines with its fields
+type Logger struct {
+	fields Fields
+}
+
+var (
+	output     io.Writer = os.Stderr
+	jsonOutput bool
+	lock       sync.Mutex
+)
+
+// SetJSON configures whether log lines are written as JSON instead of text
+func SetJSON(enabled bool) {
+	lock.Lock()
+	jsonOutput = enabled
+	lock.Unlock()
+}
+
+// SetOutput configures where log lines are written, which defaults to stderr
+func SetOutput(w io.Writer) {
+	lock.Lock()
+	output = w
+	lock.Unlock()
+}
+
+// New creates a Logger without any fields
+func New() *Logger {
+	return &Logger{fields: Fields{}}
+}
+
+// With returns a Logger that adds the fields to its lines, next to the
+// fields it already has
+func (logger *Logger) With(fields Fields) *Logger {
+	combined := Fields{}
+	for key, value := range logger.fields {
+		combined[key] = value
+	}
+	for key, value := range fields {
+		combined[key] = value
+	}
+	return &Logger{fields: combined}
+}
+
+// Info logs the message
+func (logger *Logger) Info(message string) {
+	logger.write("info", message, nil)
+}
+
+// Error logs the message with the error that occurred
+func (logger *Logger) Error(message string, err error) {
+	logger.write("error", message, err)
+}
+
+// Infof logs the formatted message
+func (logger *Logger) Infof(format string, args ...interface{}) {
+	logger.write("info", fmt.Sprintf(format, args...), nil)
+}
+
+func (logger *Logger) write(level string, message string, err error) {
+	fields := Fields{}
+	for key, value := range logger.fields {
+		fields[key] = redactField(key, value)
+	}
+	if err != nil {
+		fields["error"] = Redact(err.Error())
+	}
+	message = Redact(message)
+	lock.Lock()
+	defer lock.Unlock()
+	if jsonOutput {
+		line := Fields{}
+		for key, value := range fields {
+			line[key] = value
+		}
+		line["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+		line["level"] = level
+		line["msg"] = message
+		encoded, err := json.Marshal(line)
+		if err != nil {
+			encoded, _ = json.Marshal(Fields{"level": level, "msg": message})
+		}
+		output.Write(append(encoded, '\n'))
+		return
+	}
+	var text strings.Builder
+	text.WriteString(time.Now().Format("2006/01/02 15:04:05 "))
+	text.WriteString(strings.ToUpper(level) + " " + message)
+	keys := []string{}
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := fmt.Sprint(fields[key])
+		if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		text.WriteString(" " + key + "=" + value)
+	}
+	text.WriteString("\n")
+	io.WriteString(output, text.String())
+}
+
+// NewRequestID creates a short, random ID for correlating the log lines of
+// a request
+func NewRequestID() string {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano()%0xffffffff, 16)
+	}
+	return hex.EncodeToString(id)
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the logger
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by the context, or a Logger without
+// any fields if there isn't one
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return logger
+	}
+	return New()
+}
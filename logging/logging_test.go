@@ -0,0 +1,90 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/logging"
+)
+
+func TestLogger(t *testing.T) {
+	var output bytes.Buffer
+	logging.SetOutput(&output)
+	defer logging.SetOutput(os.Stderr)
+
+	logger := logging.New().With(logging.Fields{"request_id": "ab12cd34", "plugin": "weather"})
+	logger.Error("Plugin failed", errors.New("no city found"))
+	line := output.String()
+	for _, expected := range []string{"ERROR Plugin failed", `error="no city found"`, "plugin=weather", "request_id=ab12cd34"} {
+		if !strings.Contains(line, expected) {
+			t.Errorf("Expected %q in the log line, actual %q", expected, line)
+		}
+	}
+
+	output.Reset()
+	logging.SetJSON(true)
+	defer logging.SetJSON(false)
+	logger.Info("Plugin answered the request")
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal(output.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected a JSON line, actual %q (%s)", output.String(), err)
+	}
+	if decoded["msg"] != "Plugin answered the request" || decoded["level"] != "info" || decoded["request_id"] != "ab12cd34" {
+		t.Errorf("Expected the message, level, and fields, actual %v", decoded)
+	}
+}
+
+func TestContext(t *testing.T) {
+	logger := logging.New().With(logging.Fields{"request_id": "ab12cd34"})
+	ctx := logging.NewContext(context.Background(), logger)
+	if logging.FromContext(ctx) != logger {
+		t.Error("Expected the logger from the context")
+	}
+	if logging.FromContext(context.Background()) == nil {
+		t.Error("Expected a logger without a logger in the context")
+	}
+	if first, second := logging.NewRequestID(), logging.NewRequestID(); len(first) != 8 || first == second {
+		t.Errorf("Expected short, unique request IDs, actual %s and %s", first, second)
+	}
+}
+
+var redactTests = []struct {
+	input    string
+	expected string
+}{
+	{"Get https://api.openweathermap.org/data/2.5/weather?q=Melbourne&appid=abc123&units=metric: timeout",
+		"Get https://api.openweathermap.org/data/2.5/weather?q=Melbourne&appid=[REDACTED]&units=metric: timeout"},
+	{"Post https://hooks.slack.com/commands/T0001/1234/abcd failed", "Post [REDACTED] failed"},
+	{"token=xoxb-1234-abcd&text=help", "token=[REDACTED]&text=help"},
+	{"using xoxp-1234-5678", "using [REDACTED]"},
+	{"Post https://api.telegram.org/bot123456:ABC-def/sendMessage", "Post https://api.telegram.org/bot[REDACTED]/sendMessage"},
+	{`{"apikey": "secret-value"}`, `{"apikey": "[REDACTED]"}`},
+	{"Authorization: Bearer abcdef", "Authorization: Bearer [REDACTED]"},
+	{"Invalid token.", "Invalid token."},
+}
+
+func TestRedact(t *testing.T) {
+	for _, tt := range redactTests {
+		actual := logging.Redact(tt.input)
+		if actual != tt.expected {
+			t.Errorf("Redact(%v): expected %v, actual %v", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestRedactFields(t *testing.T) {
+	var output bytes.Buffer
+	logging.SetOutput(&output)
+	defer logging.SetOutput(os.Stderr)
+
+	logging.New().With(logging.Fields{"response_url": "https://example.com/respond", "api_token": "abc123"}).Info("Sending")
+	line := output.String()
+	if strings.Contains(line, "example.com") || strings.Contains(line, "abc123") {
+		t.Errorf("Expected the sensitive fields to be redacted, actual %q", line)
+	}
+}
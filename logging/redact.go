@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the secrets in log lines
+const Redacted = "[REDACTED]"
+
+// sensitiveFields contains the parts of field names whose values are always
+// redacted
+var sensitiveFields = []string{"token", "secret", "password", "apikey", "api_key", "appid", "authorization", "response_url", "responseurl"}
+
+// secretPatterns find secrets in text, like error messages that contain a
+// URL. The first group of a pattern is kept.
+var secretPatterns = []*regexp.Regexp{
+	// Slack tokens
+	regexp.MustCompile(`()xox[a-z]-[A-Za-z0-9-]+`),
+	// Slack's response_url and incoming webhooks
+	regexp.MustCompile(`()https://hooks\.slack\.com/[^\s"']+`),
+	// Telegram's Bot API URLs contain the token
+	regexp.MustCompile(`(/bot)[0-9]+:[A-Za-z0-9_-]+`),
+	// Query parameters and settings like token=... or "apikey": "..."
+	regexp.MustCompile(`(?i)((?:token|secret|password|api_?key|appid|response_url)"?\s*[=:]\s*"?)[^&\s"',]+`),
+	regexp.MustCompile(`(?i)((?:bearer|basic)\s+)[^\s"',]+`),
+}
+
+// Redact replaces the secrets it recognises in the text
+func Redact(text string) string {
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllString(text, "${1}"+Redacted)
+	}
+	return text
+}
+
+// redactField redacts the value of a field if its name suggests it's a
+// secret, and otherwise redacts the secrets in its text
+func redactField(key string, value interface{}) interface{} {
+	lower := strings.ToLower(key)
+	for _, sensitive := range sensitiveFields {
+		if strings.Contains(lower, sensitive) {
+			return Redacted
+		}
+	}
+	switch value := value.(type) {
+	case string:
+		return Redact(value)
+	case error:
+		return Redact(value.Error())
+	case fmt.Stringer:
+		return Redact(value.String())
+	default:
+		return value
+	}
+}
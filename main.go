@@ -1,23 +1,74 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"flag"
-	"log"
 	"net/http"
-	"net/url"
+	"os"
+	"strings"
 
-	"github.com/ArjenSchwarz/igor/slack"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/ArjenSchwarz/igor/audit"
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/logging"
+	"github.com/ArjenSchwarz/igor/metrics"
+	"github.com/ArjenSchwarz/igor/platforms"
+	"github.com/ArjenSchwarz/igor/plugins"
 )
 
+// lambdaEvent is the event the Lambda function receives. Next to the API
+// Gateway request this can be a job Igor sent to itself to run in the
+// background.
+type lambdaEvent struct {
+	events.APIGatewayProxyRequest
+	Job *asyncJob `json:"igor_job,omitempty"`
+}
+
 // Handler handles incoming Lambda requests
-func Handler(request events.APIGatewayProxyRequest) (slack.Response, error) {
-	log.Printf("Processing Lambda request %s\n", request.RequestContext.RequestID)
+func Handler(ctx context.Context, event lambdaEvent) (interface{}, error) {
+	if event.Job != nil {
+		logging.New().With(logging.Fields{"request_id": event.Job.Request.RequestID}).Info("Processing background job")
+		runAsync(ctx, *event.Job)
+		return nil, nil
+	}
+	request := event.APIGatewayProxyRequest
+	logger := logging.New().With(logging.Fields{"lambda_request_id": request.RequestContext.RequestID})
+	logger.Info("Processing Lambda request")
+	ctx = logging.NewContext(ctx, logger)
 
-	response := handle(body{Body: request.Body})
+	requestBody := request.Body
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(requestBody)
+		if err != nil {
+			return nil, err
+		}
+		requestBody = string(decoded)
+	}
+	result := findEndpoint(request.Path)(ctx, body{Body: requestBody, Headers: request.Headers})
+	statusCode := http.StatusOK
+	if reply, ok := result.(platforms.Reply); ok {
+		statusCode = reply.StatusCode
+		result = reply.Body
+	}
 
+	// Requests passed through a mapping template expect the plain result,
+	// while a proxy integration expects a full HTTP response.
+	if request.HTTPMethod == "" {
+		return result, nil
+	}
+	response := events.APIGatewayProxyResponse{StatusCode: statusCode}
+	if result != nil {
+		responseString, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		response.Headers = map[string]string{"Content-Type": "application/json; charset=utf-8"}
+		response.Body = string(responseString)
+	}
 	return response, nil
 }
 
@@ -25,38 +76,190 @@ type message struct {
 	Value string `json:"value"`
 }
 
-var servervar bool
+var (
+	servervar  bool
+	clivar     bool
+	clioptions cliOptions
+)
 
 func init() {
 	flag.BoolVar(&servervar, "server", false, "Run Igor as a server")
+	flag.BoolVar(&clivar, "cli", false, "Run the command in the arguments, or start an interactive session without arguments")
+	flag.StringVar(&clioptions.UserName, "user", cliUser(), "The user name for commands in CLI mode")
+	flag.StringVar(&clioptions.UserID, "userid", "U0CLI", "The user ID for commands in CLI mode")
+	flag.StringVar(&clioptions.ChannelName, "channel", "cli", "The channel name for commands in CLI mode")
+	flag.StringVar(&clioptions.ChannelID, "channelid", "C0CLI", "The channel ID for commands in CLI mode")
+	flag.StringVar(&clioptions.TeamDomain, "team", "cli", "The team domain for commands in CLI mode")
+	flag.StringVar(&clioptions.TeamID, "teamid", "T0CLI", "The team ID for commands in CLI mode")
+	nocolour := flag.Bool("nocolor", false, "Don't use colours in CLI mode")
 	flag.Parse()
+	clioptions.Colour = !*nocolour && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+	// Every platform's slash commands are available on a route of its own
+	for name, adapter := range platforms.GetAdapters() {
+		endpoints[name] = commandEndpoint(adapter)
+	}
 }
 
 func main() {
+	configureLogging()
+	// Querying the audit log doesn't need the plugins or routes
+	if !clivar && flag.Arg(0) == "audit" {
+		if err := runAudit(flag.Args()[1:], os.Stdout); err != nil {
+			logging.New().Error("Failed to query the audit log", err)
+			os.Exit(1)
+		}
+		return
+	}
+	configureAudit()
+	configureRoutes()
+	reportPlugins()
+	if clivar {
+		if err := runCLI(strings.Join(flag.Args(), " "), clioptions, os.Stdin, os.Stdout); err != nil {
+			logging.New().Error("Igor stopped", err)
+			os.Exit(1)
+		}
+		return
+	}
 	if servervar {
-		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path != "/" {
-				http.NotFound(w, r)
-				return
-			}
-			r.ParseForm()
-			v := url.Values{}
-			for field, values := range r.Form {
-				for _, value := range values {
-					v.Add(field, value)
-				}
-			}
-			response := handle(body{Body: v.Encode()})
-			responseString, _ := json.Marshal(response)
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.Write(responseString)
-		})
-		http.ListenAndServe(":8080", nil)
+		dispatchAsync = dispatchGoroutine
+		rateLimiter = plugins.NewMemoryLimiter()
+		generalConfig, _ := config.GeneralConfig()
+		if err := serve(generalConfig.Server); err != nil && err != http.ErrServerClosed {
+			logging.New().Error("Igor stopped", err)
+			os.Exit(1)
+		}
 	} else {
+		dispatchAsync = dispatchLambda
+		rateLimiter = lambdaLimiter()
+		// CloudWatch picks the metrics up from the function's logs
+		metrics.EnableEMF(os.Stdout)
 		lambda.Start(Handler)
 	}
 }
 
 type body struct {
-	Body string `json:"body"`
+	Body    string            `json:"body"`
+	Headers map[string]string `json:"headers"`
+}
+
+// endpoint handles the requests for a route. The context is cancelled when
+// the request is done. The result is returned as JSON,
+// unless it's nil in which case an empty response is returned. A
+// platforms.Reply is returned with its own status code.
+type endpoint func(ctx context.Context, body body) interface{}
+
+// endpoints contains the routes that are handled next to the slash command,
+// which is available at the root
+var endpoints = map[string]endpoint{
+	"events":       handleEvents,
+	"interactions": handleInteractions,
+}
+
+// configureLogging sets the format of the logs, which is text unless the
+// configuration asks for JSON
+func configureLogging() {
+	config, err := config.GeneralConfig()
+	if err != nil {
+		return
+	}
+	logging.SetJSON(strings.ToLower(config.LogFormat) == "json")
+}
+
+// configureAudit sets the sinks the audit records are written to
+func configureAudit() {
+	config, err := config.GeneralConfig()
+	if err != nil {
+		return
+	}
+	sinks, err := audit.Sinks(config.Audit)
+	if err != nil {
+		logging.New().Error("Failed to configure the audit log", err)
+	}
+	audit.SetSinks(sinks...)
+}
+
+// lambdaLimiter returns the limiter for running on Lambda. Its counts are
+// kept in DynamoDB if a table is configured, as they'd otherwise only apply
+// to a single instance of the function.
+func lambdaLimiter() plugins.Limiter {
+	config, err := config.GeneralConfig()
+	if err != nil || config.RateLimitTable == "" {
+		return plugins.NewMemoryLimiter()
+	}
+	return plugins.NewDynamoDBLimiter(config.RateLimitTable)
+}
+
+// configureRoutes applies the routes set in the configuration. The Telegram
+// webhook can be moved to a path of its own, which then replaces its default
+// route.
+func configureRoutes() {
+	config, err := config.GeneralConfig()
+	if err != nil || config.TelegramPath == "" {
+		return
+	}
+	if adapter, ok := platforms.GetAdapter("telegram"); ok {
+		delete(endpoints, adapter.Name())
+		endpoints[strings.Trim(config.TelegramPath, "/")] = commandEndpoint(adapter)
+	}
+}
+
+// reportPlugins logs the plugins that are disabled because they can't be
+// created, and the commands that are used by several plugins so they can be
+// resolved with the priority configuration
+func reportPlugins() {
+	config, err := config.GeneralConfig()
+	if err != nil {
+		return
+	}
+	for _, diagnostic := range plugins.Diagnostics(config) {
+		logging.New().Info(diagnostic.String())
+	}
+	for _, ambiguity := range plugins.Ambiguities(config) {
+		logging.New().Infof("Ambiguous command: %s", ambiguity)
+	}
+}
+
+// findEndpoint returns the endpoint for a path. The path is matched on its
+// last element so it doesn't matter where Igor is mounted in API Gateway.
+// Anything that doesn't match is treated as a slash command.
+func findEndpoint(path string) endpoint {
+	path = strings.TrimSuffix(path, "/")
+	for name, handler := range endpoints {
+		if strings.HasSuffix(path, "/"+name) {
+			return handler
+		}
+	}
+	return handleCommand
+}
+
+// handleCommand is the endpoint for slash commands sent to the root. These
+// are handled by the configured platform, which defaults to Slack.
+func handleCommand(ctx context.Context, body body) interface{} {
+	config, _ := config.GeneralConfig()
+	return commandEndpoint(platforms.DefaultAdapter(config))(ctx, body)
+}
+
+// commandEndpoint returns the endpoint for slash commands from a platform.
+// Adapters that intercept requests get to handle them first, and adapters
+// that post all their responses have the request answered without a body.
+func commandEndpoint(adapter platforms.Adapter) endpoint {
+	return func(ctx context.Context, body body) interface{} {
+		config, _ := config.GeneralConfig()
+		if interceptor, ok := adapter.(platforms.Interceptor); ok {
+			incoming := platforms.Incoming{Body: body.Body, Headers: body.Headers}
+			if result, handled := interceptor.Intercept(incoming, config); handled {
+				return result
+			}
+		}
+		request, response := handle(ctx, adapter, body)
+		if poster, ok := adapter.(platforms.Poster); ok && poster.PostsResponses() {
+			if request.ResponseURL != "" {
+				if err := adapter.PostResponse(request.ResponseURL, response, config); err != nil {
+					logging.FromContext(ctx).With(logging.Fields{"request_id": request.RequestID}).Error("Failed to send the response", err)
+				}
+			}
+			return nil
+		}
+		return adapter.Render(response, config)
+	}
 }
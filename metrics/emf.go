@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Namespace is the CloudWatch namespace the metrics are sent to
+const Namespace = "Igor"
+
+// EnableEMF writes every metric that's collected from now on as a
+// CloudWatch Embedded Metric Format line. On Lambda anything written to
+// stdout ends up in CloudWatch Logs, which turns these lines into metrics.
+func EnableEMF(w io.Writer) {
+	emfLock.Lock()
+	emf = w
+	emfLock.Unlock()
+}
+
+// emfMetadata is the _aws object describing the metric in an EMF line
+type emfMetadata struct {
+	Timestamp         int64              `json:"Timestamp"`
+	CloudWatchMetrics []emfMetricsConfig `json:"CloudWatchMetrics"`
+}
+
+type emfMetricsConfig struct {
+	Namespace  string          `json:"Namespace"`
+	Dimensions [][]string      `json:"Dimensions"`
+	Metrics    []emfDefinition `json:"Metrics"`
+}
+
+type emfDefinition struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+// writeEMF writes a single value as an EMF line, with the labels as its
+// dimensions. Nothing is written unless EMF is enabled.
+func writeEMF(name string, unit string, value float64, labels []string, values []string) {
+	emfLock.Lock()
+	defer emfLock.Unlock()
+	if emf == nil {
+		return
+	}
+	line := map[string]interface{}{
+		"_aws": emfMetadata{
+			Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+			CloudWatchMetrics: []emfMetricsConfig{{
+				Namespace:  Namespace,
+				Dimensions: [][]string{labels},
+				Metrics:    []emfDefinition{{Name: name, Unit: unit}},
+			}},
+		},
+		name: value,
+	}
+	for i, label := range labels {
+		if i < len(values) {
+			line[label] = values[i]
+		}
+	}
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	emf.Write(append(encoded, '\n'))
+}
@@ -0,0 +1,223 @@
+// Package metrics collects Igor's telemetry. The metrics are served in the
+// Prometheus text format, and can be written as CloudWatch Embedded Metric
+// Format log lines when running on Lambda.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The outcomes of requests
+const (
+	OutcomeAnswered          = "answered"
+	OutcomeUsage             = "usage"
+	OutcomeNothingFound      = "nothing_found"
+	OutcomeSomethingWrong    = "something_wrong"
+	OutcomeValidationFailure = "validation_failure"
+	OutcomeRateLimited       = "rate_limited"
+	OutcomeForbidden         = "forbidden"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the histogram buckets,
+// the same as Prometheus' defaults
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// The metrics collected by Igor
+var (
+	Requests         = NewCounter("igor_requests_total", "The requests handled, by platform and outcome.", "platform", "outcome")
+	PluginMatches    = NewCounter("igor_plugin_matches_total", "The requests answered by a plugin.", "plugin")
+	PluginErrors     = NewCounter("igor_plugin_errors_total", "The requests a plugin failed to handle.", "plugin")
+	PluginDuration   = NewHistogram("igor_plugin_duration_seconds", "How long plugins take to handle a request.", "plugin")
+	UpstreamRequests = NewCounter("igor_upstream_requests_total", "The requests sent to other services, by status.", "upstream", "status")
+	UpstreamDuration = NewHistogram("igor_upstream_duration_seconds", "How long other services take to respond.", "upstream")
+)
+
+var (
+	registered []metric
+	emf        io.Writer
+	emfLock    sync.Mutex
+)
+
+// metric is a counter or histogram that can be written in the Prometheus
+// text format
+type metric interface {
+	write(w io.Writer)
+}
+
+// Counter counts events by the values of its labels
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter creates and registers a counter
+func NewCounter(name string, help string, labels ...string) *Counter {
+	counter := &Counter{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	registered = append(registered, counter)
+	return counter
+}
+
+// Inc increases the counter for the label values by one. The values are in
+// the same order as the labels.
+func (counter *Counter) Inc(values ...string) {
+	counter.lock.Lock()
+	counter.values[strings.Join(values, "\xff")]++
+	counter.lock.Unlock()
+	writeEMF(counter.name, "Count", 1, counter.labels, values)
+}
+
+// Value returns the current value of the counter for the label values
+func (counter *Counter) Value(values ...string) float64 {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+	return counter.values[strings.Join(values, "\xff")]
+}
+
+func (counter *Counter) write(w io.Writer) {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name)
+	for _, key := range sortedKeys(counter.values) {
+		fmt.Fprintf(w, "%s%s %s\n", counter.name, labelPairs(counter.labels, key, ""), formatFloat(counter.values[key]))
+	}
+}
+
+// Histogram tracks the distribution of durations by the values of its labels
+type Histogram struct {
+	name   string
+	help   string
+	labels []string
+	lock   sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// NewHistogram creates and registers a histogram using the DefaultBuckets
+func NewHistogram(name string, help string, labels ...string) *Histogram {
+	histogram := &Histogram{name: name, help: help, labels: labels, values: make(map[string]*histogramValue)}
+	registered = append(registered, histogram)
+	return histogram
+}
+
+// Observe adds a duration for the label values. The values are in the same
+// order as the labels.
+func (histogram *Histogram) Observe(duration time.Duration, values ...string) {
+	seconds := duration.Seconds()
+	key := strings.Join(values, "\xff")
+	histogram.lock.Lock()
+	value, ok := histogram.values[key]
+	if !ok {
+		value = &histogramValue{buckets: make([]uint64, len(DefaultBuckets))}
+		histogram.values[key] = value
+	}
+	for i, bound := range DefaultBuckets {
+		if seconds <= bound {
+			value.buckets[i]++
+		}
+	}
+	value.count++
+	value.sum += seconds
+	histogram.lock.Unlock()
+	writeEMF(histogram.name, "Seconds", seconds, histogram.labels, values)
+}
+
+// Count returns the number of durations observed for the label values
+func (histogram *Histogram) Count(values ...string) uint64 {
+	histogram.lock.Lock()
+	defer histogram.lock.Unlock()
+	if value, ok := histogram.values[strings.Join(values, "\xff")]; ok {
+		return value.count
+	}
+	return 0
+}
+
+func (histogram *Histogram) write(w io.Writer) {
+	histogram.lock.Lock()
+	defer histogram.lock.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", histogram.name, histogram.help, histogram.name)
+	keys := []string{}
+	for key := range histogram.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := histogram.values[key]
+		for i, bound := range DefaultBuckets {
+			le := `le="` + formatFloat(bound) + `"`
+			fmt.Fprintf(w, "%s_bucket%s %d\n", histogram.name, labelPairs(histogram.labels, key, le), value.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", histogram.name, labelPairs(histogram.labels, key, `le="+Inf"`), value.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", histogram.name, labelPairs(histogram.labels, key, ""), formatFloat(value.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", histogram.name, labelPairs(histogram.labels, key, ""), value.count)
+	}
+}
+
+// Write writes all metrics in the Prometheus text format
+func Write(w io.Writer) {
+	for _, metric := range registered {
+		metric.write(w)
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
+
+// sortedKeys returns the keys of the counter values in order
+func sortedKeys(values map[string]float64) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// labelPairs formats the labels with the joined values in the key, followed
+// by the extra pair if it's provided
+func labelPairs(labels []string, key string, extra string) string {
+	pairs := []string{}
+	if len(labels) != 0 {
+		values := strings.Split(key, "\xff")
+		for i, label := range labels {
+			value := ""
+			if i < len(values) {
+				value = values[i]
+			}
+			pairs = append(pairs, label+`="`+labelEscaper.Replace(value)+`"`)
+		}
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelEscaper escapes label values for the Prometheus text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,103 @@
+package metrics_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/metrics"
+)
+
+func TestWrite(t *testing.T) {
+	counter := metrics.NewCounter("test_events_total", "Test events.", "kind")
+	counter.Inc("a")
+	counter.Inc("a")
+	counter.Inc(`b"c`)
+	histogram := metrics.NewHistogram("test_duration_seconds", "Test durations.", "kind")
+	histogram.Observe(20*time.Millisecond, "a")
+	histogram.Observe(3*time.Second, "a")
+
+	var output bytes.Buffer
+	metrics.Write(&output)
+	expected := []string{
+		"# TYPE test_events_total counter\n",
+		"test_events_total{kind=\"a\"} 2\n",
+		"test_events_total{kind=\"b\\\"c\"} 1\n",
+		"# TYPE test_duration_seconds histogram\n",
+		"test_duration_seconds_bucket{kind=\"a\",le=\"0.01\"} 0\n",
+		"test_duration_seconds_bucket{kind=\"a\",le=\"0.025\"} 1\n",
+		"test_duration_seconds_bucket{kind=\"a\",le=\"5\"} 2\n",
+		"test_duration_seconds_bucket{kind=\"a\",le=\"+Inf\"} 2\n",
+		"test_duration_seconds_sum{kind=\"a\"} 3.02\n",
+		"test_duration_seconds_count{kind=\"a\"} 2\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(output.String(), line) {
+			t.Errorf("Expected the output to contain %q, actual:\n%s", line, output.String())
+		}
+	}
+}
+
+func TestEnableEMF(t *testing.T) {
+	var output bytes.Buffer
+	metrics.EnableEMF(&output)
+	defer metrics.EnableEMF(nil)
+	counter := metrics.NewCounter("test_emf_total", "Test EMF.", "plugin")
+	counter.Inc("weather")
+
+	line := struct {
+		AWS struct {
+			CloudWatchMetrics []struct {
+				Namespace  string
+				Dimensions [][]string
+				Metrics    []struct{ Name, Unit string }
+			}
+		} `json:"_aws"`
+		Plugin string  `json:"plugin"`
+		Value  float64 `json:"test_emf_total"`
+	}{}
+	if err := json.Unmarshal(output.Bytes(), &line); err != nil {
+		t.Fatalf("Expected a JSON line, actual %q (%s)", output.String(), err)
+	}
+	if len(line.AWS.CloudWatchMetrics) != 1 {
+		t.Fatalf("Expected a single metrics definition, actual %q", output.String())
+	}
+	definition := line.AWS.CloudWatchMetrics[0]
+	if definition.Namespace != metrics.Namespace || len(definition.Dimensions) != 1 || definition.Dimensions[0][0] != "plugin" {
+		t.Errorf("Expected the plugin dimension in the Igor namespace, actual %q", output.String())
+	}
+	if definition.Metrics[0].Name != "test_emf_total" || definition.Metrics[0].Unit != "Count" {
+		t.Errorf("Expected the counter's definition, actual %q", output.String())
+	}
+	if line.Plugin != "weather" || line.Value != 1 {
+		t.Errorf("Expected the plugin and the value, actual %q", output.String())
+	}
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	upstream := serverURL.Hostname()
+	before := metrics.UpstreamRequests.Value(upstream, "418")
+
+	client := &http.Client{Transport: metrics.Transport{}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if actual := metrics.UpstreamRequests.Value(upstream, "418"); actual != before+1 {
+		t.Errorf("Expected the request to be counted with its status, actual %v", actual)
+	}
+	if metrics.UpstreamDuration.Count(upstream) == 0 {
+		t.Error("Expected the latency of the request to be recorded")
+	}
+}
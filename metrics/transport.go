@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Transport records the latency and status of outgoing HTTP requests, using
+// the host as the name of the upstream. Requests that fail without a
+// response have the status "error".
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// RoundTrip sends the request using the base transport, which defaults to
+// http.DefaultTransport
+func (transport Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := transport.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	ObserveUpstream(req.URL.Hostname(), status, time.Since(start))
+	return resp, err
+}
+
+// ObserveUpstream records a request to another service, for services that
+// aren't called through a Transport like DynamoDB
+func ObserveUpstream(upstream string, status string, duration time.Duration) {
+	UpstreamRequests.Inc(upstream, status)
+	UpstreamDuration.Observe(duration, upstream)
+}
@@ -0,0 +1,315 @@
+package platforms
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/helpers"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+const (
+	// DiscordSignatureHeader is the header containing the request signature
+	DiscordSignatureHeader = "X-Signature-Ed25519"
+	// DiscordTimestampHeader is the header containing the request timestamp
+	DiscordTimestampHeader = "X-Signature-Timestamp"
+	// discordEphemeral is the message flag that only shows it to the user
+	discordEphemeral = 64
+	// discordMaxEmbeds is the maximum number of embeds in a message
+	discordMaxEmbeds = 10
+)
+
+// Discord interaction and interaction response types
+const (
+	discordPing                     = 1
+	discordApplicationCommand       = 2
+	discordPong                     = 1
+	discordChannelMessageWithSource = 4
+)
+
+// Discord option types that contain further options instead of a value
+const (
+	discordSubCommand      = 1
+	discordSubCommandGroup = 2
+)
+
+// DiscordAPIURL is the base URL of the Discord API, used for sending delayed
+// responses
+var DiscordAPIURL = "https://discord.com/api/v10"
+
+// ErrInvalidDiscordSignature is returned when a request isn't signed with the
+// configured public key
+var ErrInvalidDiscordSignature = errors.New("Invalid Discord request signature")
+
+// DiscordAdapter handles Discord application command interactions
+type DiscordAdapter struct{}
+
+func init() {
+	register(DiscordAdapter{})
+}
+
+// discordInteraction contains the fields of an interaction that Igor uses
+type discordInteraction struct {
+	Type          int                `json:"type"`
+	ApplicationID string             `json:"application_id"`
+	Token         string             `json:"token"`
+	GuildID       string             `json:"guild_id"`
+	ChannelID     string             `json:"channel_id"`
+	Data          discordCommandData `json:"data"`
+	Member        *discordMember     `json:"member"`
+	User          *discordUser       `json:"user"`
+}
+
+type discordCommandData struct {
+	Name    string          `json:"name"`
+	Options []discordOption `json:"options"`
+}
+
+type discordOption struct {
+	Name    string          `json:"name"`
+	Type    int             `json:"type"`
+	Value   interface{}     `json:"value"`
+	Options []discordOption `json:"options"`
+}
+
+type discordMember struct {
+	User discordUser `json:"user"`
+}
+
+type discordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+// discordResponse is the response to an interaction
+type discordResponse struct {
+	Type int                  `json:"type"`
+	Data *discordResponseData `json:"data,omitempty"`
+}
+
+type discordResponseData struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+	Flags   int            `json:"flags,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color,omitempty"`
+	Author      *discordEmbedAuthor `json:"author,omitempty"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+	Image       *discordEmbedImage  `json:"image,omitempty"`
+	Thumbnail   *discordEmbedImage  `json:"thumbnail,omitempty"`
+}
+
+type discordEmbedAuthor struct {
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+type discordEmbedImage struct {
+	URL string `json:"url"`
+}
+
+// discordColors translates Slack's named colors
+var discordColors = map[string]int{
+	"good":    0x2eb886,
+	"warning": 0xdaa038,
+	"danger":  0xa30200,
+}
+
+// Name returns the name of the platform
+func (DiscordAdapter) Name() string {
+	return "discord"
+}
+
+// Intercept verifies the request signature, as Discord requires invalid
+// requests to be rejected with a 401 status, and answers the PING Discord
+// sends to check the endpoint.
+func (DiscordAdapter) Intercept(incoming Incoming, config config.Config) (interface{}, bool) {
+	err := VerifyDiscordSignature(config.DiscordPublicKey,
+		incoming.Body,
+		helpers.GetHeader(incoming.Headers, DiscordTimestampHeader),
+		helpers.GetHeader(incoming.Headers, DiscordSignatureHeader))
+	if err != nil {
+		return Reply{StatusCode: http.StatusUnauthorized, Body: err.Error()}, true
+	}
+	interaction := discordInteraction{}
+	if err := json.Unmarshal([]byte(incoming.Body), &interaction); err != nil {
+		return Reply{StatusCode: http.StatusBadRequest, Body: err.Error()}, true
+	}
+	if interaction.Type == discordPing {
+		return discordResponse{Type: discordPong}, true
+	}
+	return nil, false
+}
+
+// ParseRequest translates the application command into a Request. The
+// command and its options are turned into text, so /weather city:melbourne
+// becomes "weather melbourne". A command called igor passes its options on
+// as is, so it can be used as a generic entry point like a Slack command.
+func (adapter DiscordAdapter) ParseRequest(incoming Incoming) (slack.Request, error) {
+	interaction := discordInteraction{}
+	if err := json.Unmarshal([]byte(incoming.Body), &interaction); err != nil {
+		return slack.Request{}, err
+	}
+	if interaction.Type != discordApplicationCommand {
+		return slack.Request{}, fmt.Errorf("Unsupported Discord interaction type %v", interaction.Type)
+	}
+	words := discordOptionsToText(interaction.Data.Options)
+	if interaction.Data.Name != "igor" {
+		words = append([]string{interaction.Data.Name}, words...)
+	}
+	request := slack.Request{
+		TeamID:    interaction.GuildID,
+		ChannelID: interaction.ChannelID,
+		Command:   "/" + interaction.Data.Name,
+		Text:      strings.Join(words, " "),
+		Platform:  adapter.Name(),
+	}
+	user := interaction.User
+	if interaction.Member != nil {
+		user = &interaction.Member.User
+	}
+	if user != nil {
+		request.UserID = user.ID
+		request.UserName = user.Username
+	}
+	if interaction.ApplicationID != "" && interaction.Token != "" {
+		request.ResponseURL = fmt.Sprintf("%s/webhooks/%s/%s", DiscordAPIURL, interaction.ApplicationID, interaction.Token)
+	}
+	return request, nil
+}
+
+// discordOptionsToText collects the values of the options. Subcommands are
+// included by name, followed by their own options.
+func discordOptionsToText(options []discordOption) []string {
+	words := []string{}
+	for _, option := range options {
+		switch option.Type {
+		case discordSubCommand, discordSubCommandGroup:
+			words = append(words, option.Name)
+			words = append(words, discordOptionsToText(option.Options)...)
+		default:
+			if option.Value != nil {
+				words = append(words, fmt.Sprint(option.Value))
+			}
+		}
+	}
+	return words
+}
+
+// Validate ensures a public key is configured. The signature itself is
+// verified by Intercept, before the request is parsed.
+func (DiscordAdapter) Validate(request slack.Request, config config.Config) bool {
+	return config.DiscordPublicKey != ""
+}
+
+// Render translates the response into an interaction response. Attachments
+// are turned into embeds, and responses that aren't public are only shown to
+// the user who sent the command.
+func (DiscordAdapter) Render(response slack.Response, config config.Config) interface{} {
+	return discordResponse{
+		Type: discordChannelMessageWithSource,
+		Data: discordMessage(response),
+	}
+}
+
+// PostResponse sends a delayed response as a followup message
+func (DiscordAdapter) PostResponse(responseURL string, response slack.Response, config config.Config) error {
+	return postJSON(responseURL, discordMessage(response))
+}
+
+// discordMessage translates the response into the contents of a Discord message
+func discordMessage(response slack.Response) *discordResponseData {
+	data := discordResponseData{Content: slackToMarkdown(response.Text)}
+	if !response.IsPublic() {
+		data.Flags = discordEphemeral
+	}
+	for _, attach := range response.Attachments {
+		if len(data.Embeds) == discordMaxEmbeds {
+			break
+		}
+		data.Embeds = append(data.Embeds, discordAttachmentEmbed(attach))
+	}
+	return &data
+}
+
+// discordAttachmentEmbed translates an attachment into an embed
+func discordAttachmentEmbed(attach slack.Attachment) discordEmbed {
+	embed := discordEmbed{
+		Title:       slackToMarkdown(attach.Title),
+		URL:         attach.TitleLink,
+		Description: slackToMarkdown(attach.Text),
+		Color:       discordColor(attach.Color),
+	}
+	if attach.PreText != "" {
+		embed.Description = strings.TrimSpace(slackToMarkdown(attach.PreText) + "\n" + embed.Description)
+	}
+	if attach.AuthorName != "" {
+		embed.Author = &discordEmbedAuthor{Name: attach.AuthorName, URL: attach.AuthorLink, IconURL: attach.AuthorIcon}
+	}
+	for _, field := range attach.Fields {
+		embed.Fields = append(embed.Fields, discordEmbedField{
+			Name:   field.Title,
+			Value:  slackToMarkdown(field.Value),
+			Inline: field.Short,
+		})
+	}
+	if attach.ImageURL != "" {
+		embed.Image = &discordEmbedImage{URL: attach.ImageURL}
+	}
+	if attach.ThumbURL != "" {
+		embed.Thumbnail = &discordEmbedImage{URL: attach.ThumbURL}
+	}
+	return embed
+}
+
+// discordColor translates a Slack color, either named or hex, into the
+// number Discord uses
+func discordColor(color string) int {
+	if value, ok := discordColors[color]; ok {
+		return value
+	}
+	value, err := strconv.ParseInt(strings.TrimPrefix(color, "#"), 16, 32)
+	if err != nil {
+		return 0
+	}
+	return int(value)
+}
+
+// VerifyDiscordSignature checks that the body and timestamp were signed with
+// the private key belonging to the hex encoded public key
+func VerifyDiscordSignature(publicKey, body, timestamp, signature string) error {
+	if signature == "" || timestamp == "" {
+		return ErrInvalidDiscordSignature
+	}
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return ErrInvalidDiscordSignature
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return ErrInvalidDiscordSignature
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), []byte(timestamp+body), sig) {
+		return ErrInvalidDiscordSignature
+	}
+	return nil
+}
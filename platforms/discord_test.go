@@ -0,0 +1,140 @@
+package platforms_test
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/platforms"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// discordIncoming signs the body with the private key like Discord does
+func discordIncoming(key ed25519.PrivateKey, body string) platforms.Incoming {
+	timestamp := "1546300800"
+	signature := ed25519.Sign(key, []byte(timestamp+body))
+	return platforms.Incoming{
+		Body: body,
+		Headers: map[string]string{
+			"x-signature-ed25519":   hex.EncodeToString(signature),
+			"x-signature-timestamp": timestamp,
+		},
+	}
+}
+
+func TestDiscordIntercept(t *testing.T) {
+	adapter, ok := platforms.GetAdapter("discord")
+	if !ok {
+		t.Fatal("Expected the discord adapter to be registered")
+	}
+	interceptor := adapter.(platforms.Interceptor)
+	public, private, _ := ed25519.GenerateKey(nil)
+	conf := config.Config{DiscordPublicKey: hex.EncodeToString(public)}
+
+	var ping struct{ Type int }
+	result, handled := interceptor.Intercept(discordIncoming(private, `{"type":1}`), conf)
+	if !handled {
+		t.Fatal("Expected a PING to be handled")
+	}
+	renderJSON(t, result, &ping)
+	if ping.Type != 1 {
+		t.Errorf("Expected a PONG, actual %v", ping.Type)
+	}
+
+	if _, handled := interceptor.Intercept(discordIncoming(private, `{"type":2}`), conf); handled {
+		t.Error("Expected a command to be passed on")
+	}
+
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	result, handled = interceptor.Intercept(discordIncoming(otherKey, `{"type":1}`), conf)
+	reply, ok := result.(platforms.Reply)
+	if !handled || !ok || reply.StatusCode != http.StatusUnauthorized {
+		t.Error("Expected an invalid signature to be rejected with a 401")
+	}
+}
+
+func TestDiscordRequest(t *testing.T) {
+	adapter, _ := platforms.GetAdapter("discord")
+	var requestTests = []struct {
+		body     string
+		expected string
+	}{
+		{`{"type":2,"data":{"name":"weather","options":[{"name":"city","type":3,"value":"melbourne"}]}}`, "weather melbourne"},
+		{`{"type":2,"data":{"name":"xkcd","options":[{"name":"nr","type":4,"value":327}]}}`, "xkcd 327"},
+		{`{"type":2,"data":{"name":"remember","options":[{"name":"show","type":1,"options":[{"name":"name","type":3,"value":"test"}]}]}}`, "remember show test"},
+		{`{"type":2,"data":{"name":"igor","options":[{"name":"text","type":3,"value":"weather melbourne"}]}}`, "weather melbourne"},
+		{`{"type":2,"data":{"name":"help"}}`, "help"},
+	}
+	for _, tt := range requestTests {
+		request, err := adapter.ParseRequest(platforms.Incoming{Body: tt.body})
+		if err != nil {
+			t.Fatal("Unexpected error", err.Error())
+		}
+		if request.Text != tt.expected {
+			t.Errorf("Expected text %v, actual %v", tt.expected, request.Text)
+		}
+	}
+
+	body := `{"type":2,"application_id":"A1","token":"tok","guild_id":"G1","channel_id":"C1","member":{"user":{"id":"U1","username":"testuser"}},"data":{"name":"help"}}`
+	request, _ := adapter.ParseRequest(platforms.Incoming{Body: body})
+	if request.UserName != "testuser" || request.TeamID != "G1" || request.Platform != "discord" {
+		t.Error("The request details don't match the interaction")
+	}
+	if request.ResponseURL != platforms.DiscordAPIURL+"/webhooks/A1/tok" {
+		t.Errorf("Unexpected response URL %v", request.ResponseURL)
+	}
+	if adapter.Validate(request, config.Config{}) {
+		t.Error("Expected requests not to validate without a public key")
+	}
+}
+
+func TestDiscordRender(t *testing.T) {
+	adapter, _ := platforms.GetAdapter("discord")
+	response := slack.Response{Text: "*test*"}
+	attach := slack.Attachment{Title: "Title", Color: "good", ImageURL: "https://example.com/image.png"}
+	attach.AddField(slack.Field{Title: "Field", Value: "Value", Short: true})
+	response.AddAttachment(attach)
+	var rendered struct {
+		Type int
+		Data struct {
+			Content string
+			Flags   int
+			Embeds  []struct {
+				Title  string
+				Color  int
+				Image  struct{ URL string }
+				Fields []struct {
+					Name   string
+					Value  string
+					Inline bool
+				}
+			}
+		}
+	}
+	renderJSON(t, adapter.Render(response, config.Config{}), &rendered)
+	if rendered.Type != 4 || rendered.Data.Content != "**test**" {
+		t.Error("Expected a message with the converted text")
+	}
+	if rendered.Data.Flags != 64 {
+		t.Error("Expected a private response to be ephemeral")
+	}
+	if len(rendered.Data.Embeds) != 1 {
+		t.Fatal("Expected the attachment to be an embed")
+	}
+	embed := rendered.Data.Embeds[0]
+	if embed.Title != "Title" || embed.Color != 0x2eb886 || embed.Image.URL != "https://example.com/image.png" {
+		t.Error("The embed doesn't match the attachment")
+	}
+	if len(embed.Fields) != 1 || embed.Fields[0].Name != "Field" || !embed.Fields[0].Inline {
+		t.Error("Expected the field to be included in the embed")
+	}
+
+	response.SetPublic()
+	var public struct{ Data struct{ Flags int } }
+	renderJSON(t, adapter.Render(response, config.Config{}), &public)
+	if public.Data.Flags != 0 {
+		t.Error("Expected a public response not to be ephemeral")
+	}
+}
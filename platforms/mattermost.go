@@ -0,0 +1,101 @@
+package platforms
+
+import (
+	"crypto/subtle"
+	"regexp"
+	"strings"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/helpers"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// MattermostAdapter handles Mattermost slash commands
+type MattermostAdapter struct{}
+
+func init() {
+	register(MattermostAdapter{})
+}
+
+// mattermostResponse contains the fields for responding to a Mattermost
+// slash command. Mattermost supports Slack style attachments.
+type mattermostResponse struct {
+	Text         string             `json:"text"`
+	ResponseType string             `json:"response_type,omitempty"`
+	Username     string             `json:"username,omitempty"`
+	Attachments  []slack.Attachment `json:"attachments,omitempty"`
+}
+
+// Name returns the name of the platform
+func (MattermostAdapter) Name() string {
+	return "mattermost"
+}
+
+// ParseRequest translates the slash command into a Request. Mattermost uses
+// the same form fields as Slack, but also sends the token in the
+// Authorization header.
+func (adapter MattermostAdapter) ParseRequest(incoming Incoming) (slack.Request, error) {
+	request := slack.LoadRequestFromQuery(incoming.Body)
+	request.Platform = adapter.Name()
+	authorization := helpers.GetHeader(incoming.Headers, "Authorization")
+	if strings.HasPrefix(authorization, "Token ") {
+		request.Token = strings.TrimPrefix(authorization, "Token ")
+	}
+	return request, nil
+}
+
+// Validate ensures the request comes from the configured Mattermost command
+func (MattermostAdapter) Validate(request slack.Request, config config.Config) bool {
+	return config.MattermostToken != "" && subtle.ConstantTimeCompare([]byte(request.Token), []byte(config.MattermostToken)) == 1
+}
+
+// Render translates the response into a Mattermost response. Slack's markup
+// is converted to Markdown, and the blocks and actions are left out as
+// Mattermost doesn't support them.
+func (MattermostAdapter) Render(response slack.Response, config config.Config) interface{} {
+	result := mattermostResponse{
+		Text:         slackToMarkdown(response.Text),
+		ResponseType: response.ResponseType,
+		Username:     response.Username,
+	}
+	if result.ResponseType == "" {
+		result.ResponseType = "ephemeral"
+	}
+	for _, attach := range response.Attachments {
+		attach.Title = slackToMarkdown(attach.Title)
+		attach.Text = slackToMarkdown(attach.Text)
+		attach.PreText = slackToMarkdown(attach.PreText)
+		attach.Markdown = nil
+		attach.CallbackID = ""
+		attach.Actions = nil
+		fields := make([]slack.Field, len(attach.Fields))
+		for i, field := range attach.Fields {
+			field.Value = slackToMarkdown(field.Value)
+			fields[i] = field
+		}
+		if len(fields) > 0 {
+			attach.Fields = fields
+		}
+		result.Attachments = append(result.Attachments, attach)
+	}
+	return result
+}
+
+// PostResponse sends a delayed response to the response_url
+func (adapter MattermostAdapter) PostResponse(responseURL string, response slack.Response, config config.Config) error {
+	return postJSON(responseURL, adapter.Render(response, config))
+}
+
+var (
+	slackLinkRegex = regexp.MustCompile(`<((?:https?|mailto):[^|>]+)\|([^>]+)>`)
+	slackURLRegex  = regexp.MustCompile(`<((?:https?|mailto):[^|>]+)>`)
+	slackBoldRegex = regexp.MustCompile(`(^|[\s(])\*([^*\s]|[^*\s][^*\n]*[^*\s])\*`)
+)
+
+// slackToMarkdown converts Slack's mrkdwn markup into regular Markdown
+func slackToMarkdown(text string) string {
+	text = slackLinkRegex.ReplaceAllString(text, "[$2]($1)")
+	text = slackURLRegex.ReplaceAllString(text, "$1")
+	text = slackBoldRegex.ReplaceAllString(text, "$1**$2**")
+	return text
+}
@@ -0,0 +1,92 @@
+package platforms_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/platforms"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestMattermostRequest(t *testing.T) {
+	adapter, ok := platforms.GetAdapter("mattermost")
+	if !ok {
+		t.Fatal("Expected the mattermost adapter to be registered")
+	}
+	incoming := platforms.Incoming{
+		Body:    "channel_id=C1&channel_name=town-square&command=%2Figor&team_id=T1&text=weather+melbourne&user_id=U1&user_name=testuser&response_url=http%3A%2F%2Fmattermost%2Fhook",
+		Headers: map[string]string{"authorization": "Token mmtoken"},
+	}
+	request, err := adapter.ParseRequest(incoming)
+	if err != nil {
+		t.Fatal("Unexpected error", err.Error())
+	}
+	if request.Text != "weather melbourne" || request.UserName != "testuser" || request.Platform != "mattermost" {
+		t.Error("The request details don't match the form fields")
+	}
+	if !adapter.Validate(request, config.Config{MattermostToken: "mmtoken"}) {
+		t.Error("Expected the token from the header to validate")
+	}
+	if adapter.Validate(request, config.Config{Token: "mmtoken"}) {
+		t.Error("Expected the Slack token not to be used for Mattermost")
+	}
+}
+
+func TestMattermostRender(t *testing.T) {
+	adapter, _ := platforms.GetAdapter("mattermost")
+	response := slack.Response{Text: "test"}
+	attach := slack.Attachment{Title: "Title", Text: "*a* < b"}
+	attach.AddAction("xkcd", slack.NewButton("next", "Next", "2"))
+	response.AddAttachment(attach)
+	response.AddBlock(slack.NewDividerBlock())
+	var rendered struct {
+		Text         string
+		ResponseType string `json:"response_type"`
+		Blocks       []interface{}
+		Attachments  []slack.Attachment
+	}
+	renderJSON(t, adapter.Render(response, config.Config{}), &rendered)
+	if rendered.ResponseType != "ephemeral" {
+		t.Error("Expected a private response to be ephemeral")
+	}
+	if len(rendered.Blocks) != 0 {
+		t.Error("Expected blocks to be left out")
+	}
+	if len(rendered.Attachments) != 1 || rendered.Attachments[0].Actions != nil {
+		t.Fatal("Expected the attachment without its actions")
+	}
+	if rendered.Attachments[0].Text != "**a** < b" {
+		t.Errorf("Expected the attachment text to be converted, actual %v", rendered.Attachments[0].Text)
+	}
+
+	var renderTests = []struct {
+		input    string
+		expected string
+	}{
+		{"*bold*", "**bold**"},
+		{"some *bold* text", "some **bold** text"},
+		{"<https://example.com|link>", "[link](https://example.com)"},
+		{"<https://example.com>", "https://example.com"},
+		{"2 * 3 * 4", "2 * 3 * 4"},
+		{"a < b & c", "a < b & c"},
+	}
+	for _, tt := range renderTests {
+		var rendered struct{ Text string }
+		renderJSON(t, adapter.Render(slack.Response{Text: tt.input}, config.Config{}), &rendered)
+		if rendered.Text != tt.expected {
+			t.Errorf("Render(%v): expected %v, actual %v", tt.input, tt.expected, rendered.Text)
+		}
+	}
+}
+
+// renderJSON marshals the rendered response and unmarshals it into result
+func renderJSON(t *testing.T, rendered interface{}, result interface{}) {
+	data, err := json.Marshal(rendered)
+	if err != nil {
+		t.Fatal("Unexpected error", err.Error())
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		t.Fatal("Unexpected error", err.Error())
+	}
+}
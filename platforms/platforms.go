@@ -0,0 +1,110 @@
+// Package platforms provides the adapters that connect Igor to the chat
+// platforms it supports. Igor uses the Slack request and response format
+// internally, as that's what plugins are written against, and each adapter
+// translates between that and its platform.
+package platforms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/metrics"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// DefaultPlatform is the platform used when none is configured
+const DefaultPlatform = "slack"
+
+// Incoming contains the details of an incoming request, independent of the
+// platform it came from
+type Incoming struct {
+	Body    string
+	Headers map[string]string
+}
+
+// Adapter is the interface that needs to be followed by all platforms
+type Adapter interface {
+	// Name returns the name of the platform, this is used as its route
+	Name() string
+	// ParseRequest translates the incoming request into a Request
+	ParseRequest(incoming Incoming) (slack.Request, error)
+	// Validate ensures the request comes from the configured platform
+	Validate(request slack.Request, config config.Config) bool
+	// Render translates the Response into the format the platform expects
+	Render(response slack.Response, config config.Config) interface{}
+	// PostResponse sends a delayed Response to the platform
+	PostResponse(responseURL string, response slack.Response, config config.Config) error
+}
+
+// Interceptor is implemented by adapters that need to answer some requests
+// themselves before they reach the plugins, like verification checks. If the
+// request is handled, the result is returned together with true.
+type Interceptor interface {
+	Intercept(incoming Incoming, config config.Config) (interface{}, bool)
+}
+
+// Poster is implemented by adapters for platforms that don't accept a
+// response in the reply to a request. When PostsResponses returns true, every
+// response is sent using PostResponse instead of being rendered.
+type Poster interface {
+	PostsResponses() bool
+}
+
+// Reply is a result with a specific HTTP status code. Any other result is
+// returned with status 200.
+type Reply struct {
+	StatusCode int
+	Body       interface{}
+}
+
+// adapters contains all the supported platforms
+var adapters = map[string]Adapter{}
+
+// register adds an adapter to the supported platforms
+func register(adapter Adapter) {
+	adapters[adapter.Name()] = adapter
+}
+
+// GetAdapter retrieves the adapter for the platform
+func GetAdapter(platform string) (Adapter, bool) {
+	adapter, ok := adapters[platform]
+	return adapter, ok
+}
+
+// GetAdapters retrieves the adapters of all supported platforms
+func GetAdapters() map[string]Adapter {
+	return adapters
+}
+
+// DefaultAdapter retrieves the adapter for the configured platform. This is
+// used for requests that don't specify a platform.
+func DefaultAdapter(config config.Config) Adapter {
+	if adapter, ok := GetAdapter(config.Platform); ok {
+		return adapter
+	}
+	return adapters[DefaultPlatform]
+}
+
+// httpClient is the client used for sending delayed responses
+var httpClient = &http.Client{Timeout: 10 * time.Second, Transport: metrics.Transport{}}
+
+// postJSON sends the payload as JSON to the URL
+func postJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Posting the response failed with status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package platforms
+
+import (
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// SlackAdapter handles Slack slash commands
+type SlackAdapter struct{}
+
+func init() {
+	register(SlackAdapter{})
+}
+
+// Name returns the name of the platform
+func (SlackAdapter) Name() string {
+	return "slack"
+}
+
+// ParseRequest translates the slash command into a Request
+func (adapter SlackAdapter) ParseRequest(incoming Incoming) (slack.Request, error) {
+	request := slack.LoadRequest(incoming.Body, incoming.Headers)
+	request.Platform = adapter.Name()
+	return request, nil
+}
+
+// Validate ensures the request comes from the configured Slack team
+func (SlackAdapter) Validate(request slack.Request, config config.Config) bool {
+	return request.Validate(config)
+}
+
+// Render escapes the response, and ensures Block Kit is used where available
+func (SlackAdapter) Render(response slack.Response, config config.Config) interface{} {
+	response.Escape()
+	response.PrepareBlocks(config.BlockKit)
+	return response
+}
+
+// PostResponse sends a delayed response to the response_url
+func (adapter SlackAdapter) PostResponse(responseURL string, response slack.Response, config config.Config) error {
+	return slack.PostResponse(responseURL, adapter.Render(response, config).(slack.Response))
+}
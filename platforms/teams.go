@@ -0,0 +1,233 @@
+package platforms
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/helpers"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// TeamsAdaptiveCard is the content type of an Adaptive Card attachment
+const TeamsAdaptiveCard = "application/vnd.microsoft.card.adaptive"
+
+// ErrInvalidTeamsSignature is returned when a request isn't signed with the
+// configured security token
+var ErrInvalidTeamsSignature = errors.New("Invalid Teams request signature")
+
+// TeamsAdapter handles Microsoft Teams outgoing webhooks
+type TeamsAdapter struct{}
+
+func init() {
+	register(TeamsAdapter{})
+}
+
+// teamsActivity contains the fields of an outgoing webhook message that
+// Igor uses
+type teamsActivity struct {
+	Type         string       `json:"type"`
+	Text         string       `json:"text"`
+	From         teamsAccount `json:"from"`
+	Conversation teamsAccount `json:"conversation"`
+	ChannelData  struct {
+		Team    teamsAccount `json:"team"`
+		Channel teamsAccount `json:"channel"`
+	} `json:"channelData"`
+}
+
+type teamsAccount struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// teamsMessage is the message sent back to Teams
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Text        string            `json:"text,omitempty"`
+	Attachments []teamsAttachment `json:"attachments,omitempty"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+// adaptiveCard contains the parts of an Adaptive Card used by Igor
+type adaptiveCard struct {
+	Type    string                   `json:"type"`
+	Version string                   `json:"version"`
+	Body    []map[string]interface{} `json:"body"`
+	Actions []map[string]interface{} `json:"actions,omitempty"`
+}
+
+var (
+	teamsMentionRegex = regexp.MustCompile(`<at>[^<]*</at>`)
+	teamsTagRegex     = regexp.MustCompile(`<[^>]+>`)
+)
+
+// teamsColors translates Slack's named colors into Adaptive Card colors
+var teamsColors = map[string]string{
+	"good":    "good",
+	"warning": "warning",
+	"danger":  "attention",
+}
+
+// Name returns the name of the platform
+func (TeamsAdapter) Name() string {
+	return "teams"
+}
+
+// Intercept verifies the HMAC signature of the request and rejects it with a
+// 401 status if it doesn't match
+func (TeamsAdapter) Intercept(incoming Incoming, config config.Config) (interface{}, bool) {
+	err := VerifyTeamsSignature(config.TeamsSecret, incoming.Body, helpers.GetHeader(incoming.Headers, "Authorization"))
+	if err != nil {
+		return Reply{StatusCode: http.StatusUnauthorized, Body: err.Error()}, true
+	}
+	return nil, false
+}
+
+// ParseRequest translates the message into a Request. The mention of the
+// webhook and any other markup is removed from the text.
+func (adapter TeamsAdapter) ParseRequest(incoming Incoming) (slack.Request, error) {
+	activity := teamsActivity{}
+	if err := json.Unmarshal([]byte(incoming.Body), &activity); err != nil {
+		return slack.Request{}, err
+	}
+	text := teamsMentionRegex.ReplaceAllString(activity.Text, "")
+	text = teamsTagRegex.ReplaceAllString(text, "")
+	text = strings.Replace(html.UnescapeString(text), "\u00a0", " ", -1)
+	return slack.Request{
+		TeamID:      activity.ChannelData.Team.ID,
+		TeamDomain:  activity.ChannelData.Team.Name,
+		ChannelID:   activity.Conversation.ID,
+		ChannelName: activity.ChannelData.Channel.Name,
+		UserID:      activity.From.ID,
+		UserName:    activity.From.Name,
+		Text:        strings.TrimSpace(text),
+		Platform:    adapter.Name(),
+	}, nil
+}
+
+// Validate ensures a security token is configured. The signature itself is
+// verified by Intercept, before the request is parsed.
+func (TeamsAdapter) Validate(request slack.Request, config config.Config) bool {
+	return config.TeamsSecret != ""
+}
+
+// Render translates the response into a Teams message, with every
+// attachment shown as an Adaptive Card. Teams replies are always visible to
+// the channel.
+func (TeamsAdapter) Render(response slack.Response, config config.Config) interface{} {
+	message := teamsMessage{
+		Type: "message",
+		Text: slackToMarkdown(response.Text),
+	}
+	for _, attach := range response.Attachments {
+		message.Attachments = append(message.Attachments, teamsAttachment{
+			ContentType: TeamsAdaptiveCard,
+			Content:     attachmentCard(attach),
+		})
+	}
+	return message
+}
+
+// PostResponse isn't supported, as outgoing webhooks can only be answered
+// directly
+func (TeamsAdapter) PostResponse(responseURL string, response slack.Response, config config.Config) error {
+	return errors.New("Teams outgoing webhooks don't support delayed responses")
+}
+
+// attachmentCard translates an attachment into an Adaptive Card. The fields
+// are shown as a fact set and the title link as a button.
+func attachmentCard(attach slack.Attachment) adaptiveCard {
+	card := adaptiveCard{Type: "AdaptiveCard", Version: "1.2"}
+	if attach.PreText != "" {
+		card.Body = append(card.Body, textBlock(attach.PreText, nil))
+	}
+	if attach.AuthorName != "" {
+		card.Body = append(card.Body, textBlock(attach.AuthorName, map[string]interface{}{"isSubtle": true}))
+	}
+	if attach.Title != "" {
+		options := map[string]interface{}{"weight": "bolder", "size": "medium"}
+		if color, ok := teamsColors[attach.Color]; ok {
+			options["color"] = color
+		}
+		card.Body = append(card.Body, textBlock(attach.Title, options))
+	}
+	if attach.Text != "" {
+		card.Body = append(card.Body, textBlock(attach.Text, nil))
+	}
+	if len(attach.Fields) > 0 {
+		facts := []map[string]string{}
+		for _, field := range attach.Fields {
+			facts = append(facts, map[string]string{
+				"title": field.Title,
+				"value": slackToMarkdown(field.Value),
+			})
+		}
+		card.Body = append(card.Body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+	for _, url := range []string{attach.ImageURL, attach.ThumbURL} {
+		if url != "" {
+			card.Body = append(card.Body, map[string]interface{}{
+				"type":    "Image",
+				"url":     url,
+				"altText": attach.Title,
+			})
+			break
+		}
+	}
+	if attach.TitleLink != "" {
+		card.Actions = append(card.Actions, map[string]interface{}{
+			"type":  "Action.OpenUrl",
+			"title": attach.Title,
+			"url":   attach.TitleLink,
+		})
+	}
+	return card
+}
+
+// textBlock creates a wrapping TextBlock with the provided extra options
+func textBlock(text string, options map[string]interface{}) map[string]interface{} {
+	block := map[string]interface{}{
+		"type": "TextBlock",
+		"text": slackToMarkdown(text),
+		"wrap": true,
+	}
+	for key, value := range options {
+		block[key] = value
+	}
+	return block
+}
+
+// VerifyTeamsSignature checks the HMAC in the Authorization header. The
+// security token Teams provides is base64 encoded and used as the key for a
+// SHA256 HMAC of the body.
+func VerifyTeamsSignature(secret, body, authorization string) error {
+	if !strings.HasPrefix(authorization, "HMAC ") {
+		return ErrInvalidTeamsSignature
+	}
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return ErrInvalidTeamsSignature
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(authorization, "HMAC "))
+	if err != nil {
+		return ErrInvalidTeamsSignature
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(body))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return ErrInvalidTeamsSignature
+	}
+	return nil
+}
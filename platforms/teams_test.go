@@ -0,0 +1,115 @@
+package platforms_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/platforms"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+const teamsSecret = "c2VjdXJpdHkgdG9rZW4="
+
+// teamsIncoming signs the body with the security token like Teams does
+func teamsIncoming(secret, body string) platforms.Incoming {
+	key, _ := base64.StdEncoding.DecodeString(secret)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(body))
+	return platforms.Incoming{
+		Body:    body,
+		Headers: map[string]string{"authorization": "HMAC " + base64.StdEncoding.EncodeToString(mac.Sum(nil))},
+	}
+}
+
+func TestTeamsIntercept(t *testing.T) {
+	adapter, ok := platforms.GetAdapter("teams")
+	if !ok {
+		t.Fatal("Expected the teams adapter to be registered")
+	}
+	interceptor := adapter.(platforms.Interceptor)
+	conf := config.Config{TeamsSecret: teamsSecret}
+	body := `{"type":"message","text":"<at>Igor</at> help"}`
+	if _, handled := interceptor.Intercept(teamsIncoming(teamsSecret, body), conf); handled {
+		t.Error("Expected a correctly signed request to be passed on")
+	}
+	result, handled := interceptor.Intercept(teamsIncoming("b3RoZXIgdG9rZW4=", body), conf)
+	reply, ok := result.(platforms.Reply)
+	if !handled || !ok || reply.StatusCode != http.StatusUnauthorized {
+		t.Error("Expected an invalid signature to be rejected with a 401")
+	}
+}
+
+func TestTeamsRequest(t *testing.T) {
+	adapter, _ := platforms.GetAdapter("teams")
+	var requestTests = []struct {
+		text     string
+		expected string
+	}{
+		{"<at>Igor</at> weather melbourne", "weather melbourne"},
+		{"<at>Igor</at>&nbsp;xkcd 327\\n", "xkcd 327"},
+		{"<p><at>Igor</at> remember <b>test</b></p>", "remember test"},
+	}
+	for _, tt := range requestTests {
+		body := `{"type":"message","text":"` + tt.text + `","from":{"id":"U1","name":"Test User"},"conversation":{"id":"C1"}}`
+		request, err := adapter.ParseRequest(platforms.Incoming{Body: body})
+		if err != nil {
+			t.Fatal("Unexpected error", err.Error())
+		}
+		if request.Text != tt.expected {
+			t.Errorf("Expected text %v, actual %v", tt.expected, request.Text)
+		}
+		if request.UserName != "Test User" || request.ChannelID != "C1" || request.Platform != "teams" {
+			t.Error("The request details don't match the message")
+		}
+	}
+}
+
+func TestTeamsRender(t *testing.T) {
+	adapter, _ := platforms.GetAdapter("teams")
+	response := slack.Response{Text: "*test*"}
+	attach := slack.Attachment{Title: "Title", TitleLink: "https://example.com", ImageURL: "https://example.com/image.png"}
+	attach.AddField(slack.Field{Title: "Field", Value: "Value", Short: true})
+	response.AddAttachment(attach)
+	var rendered struct {
+		Type        string
+		Text        string
+		Attachments []struct {
+			ContentType string
+			Content     struct {
+				Type string
+				Body []struct {
+					Type  string
+					Text  string
+					URL   string
+					Facts []struct{ Title, Value string }
+				}
+				Actions []struct{ Type, URL string }
+			}
+		}
+	}
+	renderJSON(t, adapter.Render(response, config.Config{}), &rendered)
+	if rendered.Type != "message" || rendered.Text != "**test**" {
+		t.Error("Expected a message with the converted text")
+	}
+	if len(rendered.Attachments) != 1 || rendered.Attachments[0].ContentType != platforms.TeamsAdaptiveCard {
+		t.Fatal("Expected the attachment to be an Adaptive Card")
+	}
+	card := rendered.Attachments[0].Content
+	types := []string{}
+	for _, element := range card.Body {
+		types = append(types, element.Type)
+	}
+	if len(types) != 3 || types[0] != "TextBlock" || types[1] != "FactSet" || types[2] != "Image" {
+		t.Fatalf("Unexpected card body %v", types)
+	}
+	if card.Body[1].Facts[0].Title != "Field" || card.Body[2].URL != "https://example.com/image.png" {
+		t.Error("Expected the fields and image to carry over")
+	}
+	if len(card.Actions) != 1 || card.Actions[0].URL != "https://example.com" {
+		t.Error("Expected the title link as an action")
+	}
+}
@@ -0,0 +1,265 @@
+package platforms
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/helpers"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// TelegramSecretHeader is the header containing the secret token configured
+// for the webhook
+const TelegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// ErrInvalidTelegramSecret is returned when a request doesn't contain the
+// configured secret token
+var ErrInvalidTelegramSecret = errors.New("Invalid Telegram secret token")
+
+// TelegramAdapter handles Telegram bot webhook updates
+type TelegramAdapter struct{}
+
+func init() {
+	register(TelegramAdapter{})
+}
+
+// telegramUpdate contains the fields of a webhook update that Igor uses
+type telegramUpdate struct {
+	UpdateID int              `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+type telegramMessage struct {
+	MessageID int          `json:"message_id"`
+	From      telegramUser `json:"from"`
+	Chat      telegramChat `json:"chat"`
+	Text      string       `json:"text"`
+}
+
+type telegramUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+type telegramChat struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Username string `json:"username"`
+}
+
+// telegramSendMessage contains the parameters of the sendMessage method
+type telegramSendMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview,omitempty"`
+}
+
+// telegramSendPhoto contains the parameters of the sendPhoto method
+type telegramSendPhoto struct {
+	ChatID    string `json:"chat_id"`
+	Photo     string `json:"photo"`
+	Caption   string `json:"caption,omitempty"`
+	ParseMode string `json:"parse_mode"`
+}
+
+// Name returns the name of the platform
+func (TelegramAdapter) Name() string {
+	return "telegram"
+}
+
+// Intercept verifies the secret token and rejects the request with a 401
+// status if it doesn't match. Updates that aren't text messages are
+// acknowledged without further handling, as Telegram keeps resending updates
+// that fail.
+func (TelegramAdapter) Intercept(incoming Incoming, config config.Config) (interface{}, bool) {
+	secret := helpers.GetHeader(incoming.Headers, TelegramSecretHeader)
+	if config.TelegramSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(config.TelegramSecret)) != 1 {
+		return Reply{StatusCode: http.StatusUnauthorized, Body: ErrInvalidTelegramSecret.Error()}, true
+	}
+	update := telegramUpdate{}
+	if err := json.Unmarshal([]byte(incoming.Body), &update); err != nil {
+		return Reply{StatusCode: http.StatusBadRequest, Body: err.Error()}, true
+	}
+	if update.Message == nil || update.Message.Text == "" {
+		return nil, true
+	}
+	return nil, false
+}
+
+// ParseRequest translates the message into a Request. The leading slash and
+// bot name are removed from commands, so /weather@IgorBot melbourne becomes
+// "weather melbourne". A /igor command passes the rest of its text on as is.
+// The chat is used as the response URL, as responses are sent through the
+// Bot API.
+func (adapter TelegramAdapter) ParseRequest(incoming Incoming) (slack.Request, error) {
+	update := telegramUpdate{}
+	if err := json.Unmarshal([]byte(incoming.Body), &update); err != nil {
+		return slack.Request{}, err
+	}
+	if update.Message == nil {
+		return slack.Request{}, errors.New("The Telegram update doesn't contain a message")
+	}
+	message := update.Message
+	chatID := strconv.FormatInt(message.Chat.ID, 10)
+	request := slack.Request{
+		ChannelID:   chatID,
+		ChannelName: message.Chat.Title,
+		UserID:      strconv.FormatInt(message.From.ID, 10),
+		UserName:    message.From.Username,
+		Text:        telegramCommandText(message.Text),
+		ResponseURL: chatID,
+		Platform:    adapter.Name(),
+	}
+	if request.ChannelName == "" {
+		request.ChannelName = message.Chat.Username
+	}
+	return request, nil
+}
+
+// telegramCommandText removes the slash and bot name from a command
+func telegramCommandText(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return text
+	}
+	words := strings.SplitN(text[1:], " ", 2)
+	command := strings.SplitN(words[0], "@", 2)[0]
+	rest := ""
+	if len(words) == 2 {
+		rest = strings.TrimSpace(words[1])
+	}
+	if command == "igor" {
+		return rest
+	}
+	return strings.TrimSpace(command + " " + rest)
+}
+
+// Validate ensures the bot is configured. The secret token itself is
+// verified by Intercept, before the request is parsed.
+func (TelegramAdapter) Validate(request slack.Request, config config.Config) bool {
+	return config.TelegramToken != "" && config.TelegramSecret != ""
+}
+
+// PostsResponses returns true, as all responses are sent through the Bot API
+func (TelegramAdapter) PostsResponses() bool {
+	return true
+}
+
+// Render returns nothing, as the responses are sent using PostResponse
+func (TelegramAdapter) Render(response slack.Response, config config.Config) interface{} {
+	return nil
+}
+
+// PostResponse sends the response to the chat. The text and attachments
+// without images are sent as a single message, while every attachment with
+// an image is sent as a photo with the rest of the attachment as its
+// caption.
+func (TelegramAdapter) PostResponse(chatID string, response slack.Response, config config.Config) error {
+	messages := []string{}
+	if response.Text != "" {
+		messages = append(messages, slackToHTML(response.Text))
+	}
+	photos := []telegramSendPhoto{}
+	for _, attach := range response.Attachments {
+		text := telegramAttachment(attach)
+		photo := attach.ImageURL
+		if photo == "" {
+			photo = attach.ThumbURL
+		}
+		if photo == "" {
+			if text != "" {
+				messages = append(messages, text)
+			}
+			continue
+		}
+		photos = append(photos, telegramSendPhoto{ChatID: chatID, Photo: photo, Caption: text, ParseMode: "HTML"})
+	}
+	if len(messages) > 0 {
+		message := telegramSendMessage{
+			ChatID:                chatID,
+			Text:                  strings.Join(messages, "\n\n"),
+			ParseMode:             "HTML",
+			DisableWebPagePreview: true,
+		}
+		if err := postJSON(telegramMethodURL(config, "sendMessage"), message); err != nil {
+			return err
+		}
+	}
+	for _, photo := range photos {
+		if err := postJSON(telegramMethodURL(config, "sendPhoto"), photo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// telegramMethodURL returns the URL for calling a Bot API method
+func telegramMethodURL(config config.Config, method string) string {
+	return fmt.Sprintf("%s/bot%s/%s", strings.TrimSuffix(config.TelegramAPIURL, "/"), config.TelegramToken, method)
+}
+
+// telegramAttachment renders the attachment, without its image, as HTML
+func telegramAttachment(attach slack.Attachment) string {
+	lines := []string{}
+	if attach.PreText != "" {
+		lines = append(lines, slackToHTML(attach.PreText))
+	}
+	if attach.AuthorName != "" {
+		lines = append(lines, "<i>"+html.EscapeString(attach.AuthorName)+"</i>")
+	}
+	if attach.Title != "" {
+		title := html.EscapeString(attach.Title)
+		if attach.TitleLink != "" {
+			title = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(attach.TitleLink), title)
+		}
+		lines = append(lines, "<b>"+title+"</b>")
+	}
+	if attach.Text != "" {
+		lines = append(lines, slackToHTML(attach.Text))
+	}
+	for _, field := range attach.Fields {
+		lines = append(lines, fmt.Sprintf("<b>%s</b>: %s", html.EscapeString(field.Title), slackToHTML(field.Value)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	slackAnyLinkRegex = regexp.MustCompile(`<((?:https?|mailto):[^|>]+)(?:\|([^>]+))?>`)
+	slackItalicRegex  = regexp.MustCompile(`(^|[\s(])_([^_\s]|[^_\s][^_\n]*[^_\s])_`)
+	slackCodeRegex    = regexp.MustCompile("`([^`\n]+)`")
+)
+
+// slackToHTML converts Slack's mrkdwn markup into the HTML supported by
+// Telegram. Everything that isn't markup is escaped.
+func slackToHTML(text string) string {
+	result := ""
+	last := 0
+	for _, match := range slackAnyLinkRegex.FindAllStringSubmatchIndex(text, -1) {
+		result += formatHTML(text[last:match[0]])
+		url := text[match[2]:match[3]]
+		label := url
+		if match[4] != -1 {
+			label = text[match[4]:match[5]]
+		}
+		result += fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(url), html.EscapeString(label))
+		last = match[1]
+	}
+	return result + formatHTML(text[last:])
+}
+
+// formatHTML escapes the text and converts bold, italic and code markup
+func formatHTML(text string) string {
+	text = html.EscapeString(text)
+	text = slackBoldRegex.ReplaceAllString(text, "$1<b>$2</b>")
+	text = slackItalicRegex.ReplaceAllString(text, "$1<i>$2</i>")
+	return slackCodeRegex.ReplaceAllString(text, "<code>$1</code>")
+}
@@ -0,0 +1,113 @@
+package platforms_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/platforms"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestTelegramIntercept(t *testing.T) {
+	adapter, ok := platforms.GetAdapter("telegram")
+	if !ok {
+		t.Fatal("Expected the telegram adapter to be registered")
+	}
+	interceptor := adapter.(platforms.Interceptor)
+	conf := config.Config{TelegramSecret: "secret"}
+	var interceptTests = []struct {
+		secret  string
+		body    string
+		handled bool
+		status  int
+	}{
+		{"secret", `{"update_id":1,"message":{"text":"/help"}}`, false, 0},
+		{"secret", `{"update_id":1,"edited_message":{"text":"/help"}}`, true, 0},
+		{"secret", `{"update_id":1,"message":{"photo":[]}}`, true, 0},
+		{"wrong", `{"update_id":1,"message":{"text":"/help"}}`, true, http.StatusUnauthorized},
+		{"", `{"update_id":1,"message":{"text":"/help"}}`, true, http.StatusUnauthorized},
+	}
+	for _, tt := range interceptTests {
+		incoming := platforms.Incoming{
+			Body:    tt.body,
+			Headers: map[string]string{"x-telegram-bot-api-secret-token": tt.secret},
+		}
+		result, handled := interceptor.Intercept(incoming, conf)
+		if handled != tt.handled {
+			t.Errorf("Intercept(%v): expected handled %v, actual %v", tt.body, tt.handled, handled)
+		}
+		reply, _ := result.(platforms.Reply)
+		if reply.StatusCode != tt.status {
+			t.Errorf("Intercept(%v): expected status %v, actual %v", tt.body, tt.status, reply.StatusCode)
+		}
+	}
+}
+
+func TestTelegramRequest(t *testing.T) {
+	adapter, _ := platforms.GetAdapter("telegram")
+	var requestTests = []struct {
+		text     string
+		expected string
+	}{
+		{"/weather melbourne", "weather melbourne"},
+		{"/weather@IgorBot melbourne", "weather melbourne"},
+		{"/help", "help"},
+		{"/igor xkcd 327", "xkcd 327"},
+		{"tumblr devops", "tumblr devops"},
+	}
+	for _, tt := range requestTests {
+		body := `{"update_id":1,"message":{"message_id":2,"from":{"id":3,"username":"testuser"},"chat":{"id":-4,"title":"Chat"},"text":"` + tt.text + `"}}`
+		request, err := adapter.ParseRequest(platforms.Incoming{Body: body})
+		if err != nil {
+			t.Fatal("Unexpected error", err.Error())
+		}
+		if request.Text != tt.expected {
+			t.Errorf("Expected text %v, actual %v", tt.expected, request.Text)
+		}
+		if request.ResponseURL != "-4" || request.UserName != "testuser" || request.Platform != "telegram" {
+			t.Error("The request details don't match the message")
+		}
+	}
+}
+
+func TestTelegramPostResponse(t *testing.T) {
+	calls := []string{}
+	payloads := []map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		calls = append(calls, r.URL.Path)
+		payloads = append(payloads, payload)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+	adapter, _ := platforms.GetAdapter("telegram")
+	conf := config.Config{TelegramToken: "token", TelegramAPIURL: server.URL}
+
+	response := slack.Response{Text: "*Weather* for <https://example.com|Melbourne> & surrounds"}
+	response.AddAttachment(slack.Attachment{Title: "Today", Fields: []slack.Field{{Title: "Temperature", Value: "20"}}})
+	response.AddAttachment(slack.Attachment{Title: "Comic", ImageURL: "https://example.com/comic.png"})
+	if err := adapter.PostResponse("-4", response, conf); err != nil {
+		t.Fatal("Unexpected error", err.Error())
+	}
+	if len(calls) != 2 || calls[0] != "/bottoken/sendMessage" || calls[1] != "/bottoken/sendPhoto" {
+		t.Fatalf("Unexpected calls %v", calls)
+	}
+	expected := "<b>Weather</b> for <a href=\"https://example.com\">Melbourne</a> &amp; surrounds\n\n<b>Today</b>\n<b>Temperature</b>: 20"
+	if payloads[0]["text"] != expected || payloads[0]["chat_id"] != "-4" || payloads[0]["parse_mode"] != "HTML" {
+		t.Errorf("Unexpected message %v", payloads[0])
+	}
+	if payloads[1]["photo"] != "https://example.com/comic.png" || payloads[1]["caption"] != "<b>Comic</b>" {
+		t.Errorf("Unexpected photo %v", payloads[1])
+	}
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"ok":false}`, http.StatusBadRequest)
+	})
+	if err := adapter.PostResponse("-4", slack.Response{Text: "test"}, conf); err == nil {
+		t.Error("Expected an error when the Bot API call fails")
+	}
+}
@@ -0,0 +1,186 @@
+package plugins
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// ParamType is the type of value a command parameter accepts
+type ParamType int
+
+const (
+	// StringParam is a single word
+	StringParam ParamType = iota
+	// IntParam is a whole number
+	IntParam
+	// URLParam is an http or https URL
+	URLParam
+	// RestParam is the remainder of the message, which can contain spaces
+	RestParam
+)
+
+// Param describes a parameter in a command template. Parameters are matched
+// to the placeholders in the template by position, so their names don't
+// depend on the language.
+type Param struct {
+	Name     string
+	Type     ParamType
+	Optional bool
+}
+
+// Args contains the parsed arguments of a command by parameter name. Only
+// the arguments that were provided are included.
+type Args map[string]interface{}
+
+// Has checks if the argument was provided
+func (args Args) Has(name string) bool {
+	_, ok := args[name]
+	return ok
+}
+
+// String returns the argument as text
+func (args Args) String(name string) string {
+	switch value := args[name].(type) {
+	case string:
+		return value
+	case int:
+		return strconv.Itoa(value)
+	case *url.URL:
+		return value.String()
+	}
+	return ""
+}
+
+// Int returns the value of an IntParam argument
+func (args Args) Int(name string) int {
+	value, _ := args[name].(int)
+	return value
+}
+
+// URL returns the value of a URLParam argument
+func (args Args) URL(name string) *url.URL {
+	value, _ := args[name].(*url.URL)
+	return value
+}
+
+// Command is the plugin command that matched the message, with its parsed
+// arguments
+type Command struct {
+	Name     string
+	Language string
+	Template string
+	Args     Args
+}
+
+// UsageError indicates the message matched a command, but its arguments
+// aren't valid. The message is in the language of the command.
+type UsageError struct {
+	Template string
+	Message  string
+	Usage    string
+}
+
+// Error returns a string interpretation of the UsageError
+func (e *UsageError) Error() string {
+	return "Invalid usage of " + e.Template + ": " + e.Message
+}
+
+// Response returns the response explaining how to use the command
+func (e *UsageError) Response() slack.Response {
+	response := slack.Response{}
+	response.Text = e.Message + "\n" + e.Usage
+	return response
+}
+
+// usageTexts contains the fallback texts for usage errors, rate limits, and
+// permissions, for languages that don't provide them
+var usageTexts = map[string]string{
+	"usage":            "Usage: *[replace]*",
+	"missing_argument": "Please provide [replace]",
+	"invalid_number":   "[replace] should be a number",
+	"invalid_url":      "[replace] should be a URL",
+	"invalid_word":     "[replace] should be a single word",
+	"slow_down":        "Slow down! You've used [replace] a lot, please try again in a bit.",
+	"forbidden":        "You aren't allowed to use *[replace]*",
+}
+
+// getCommand finds the plugin's command that best matches the message and
+// parses its arguments using the provided parameters per command. It returns
+// a NoMatchError if no command matches, and a UsageError if the arguments
+// aren't valid.
+func getCommand(plugin IgorPlugin, params map[string][]Param) (Command, error) {
+	generalConfig := teamConfig(plugin)
+	plugins := map[string]IgorPlugin{plugin.Name(): plugin}
+	matches := matchRoutes(plugins, plugin.Message(), generalConfig)
+	if len(matches) == 0 {
+		return Command{}, CreateNoMatchError("Nothing found")
+	}
+	match := matches[0]
+	command := Command{
+		Name:     match.Route.Command,
+		Language: match.Route.Language,
+		Template: match.Route.Template,
+		Args:     make(Args),
+	}
+	for i, param := range params[command.Name] {
+		placeholder := param.Name
+		value := ""
+		if i < len(match.Route.Params) {
+			placeholder = match.Route.Params[i]
+			value = strings.TrimSpace(match.Args[i])
+		}
+		if value == "" {
+			if !param.Optional {
+				return command, newUsageError(command, "missing_argument", placeholder, generalConfig)
+			}
+			continue
+		}
+		switch param.Type {
+		case StringParam:
+			if len(strings.Fields(value)) > 1 {
+				return command, newUsageError(command, "invalid_word", placeholder, generalConfig)
+			}
+			command.Args[param.Name] = value
+		case IntParam:
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return command, newUsageError(command, "invalid_number", placeholder, generalConfig)
+			}
+			command.Args[param.Name] = number
+		case URLParam:
+			parsed, err := url.ParseRequestURI(value)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return command, newUsageError(command, "invalid_url", placeholder, generalConfig)
+			}
+			command.Args[param.Name] = parsed
+		case RestParam:
+			command.Args[param.Name] = value
+		}
+	}
+	return command, nil
+}
+
+// newUsageError creates a UsageError for the command, with the text for the
+// reason in the language of the command
+func newUsageError(command Command, reason string, placeholder string, generalConfig config.Config) *UsageError {
+	return &UsageError{
+		Template: command.Template,
+		Message:  strings.Replace(usageText(reason, command.Language, generalConfig), "[replace]", placeholder, 1),
+		Usage:    strings.Replace(usageText("usage", command.Language, generalConfig), "[replace]", command.Template, 1),
+	}
+}
+
+// usageText retrieves a usage text for the language, falling back to the
+// default language and then to English
+func usageText(key string, language string, generalConfig config.Config) string {
+	for _, name := range []string{language, generalConfig.DefaultLanguage} {
+		if text, ok := generalConfig.Languages[name].Language[key]; ok {
+			return text
+		}
+	}
+	return usageTexts[key]
+}
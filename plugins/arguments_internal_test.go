@@ -0,0 +1,88 @@
+package plugins
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestGetCommand(t *testing.T) {
+	err := os.Setenv("IGOR_CONFIG", "{\"token\": \"testtoken\", \"languagedir\": \"../language\"}")
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	languages := map[string]config.LanguagePluginDetails{
+		"english.yml": {Commands: map[string]config.LanguagePluginCommandDetails{
+			"save":  {Command: "save [name] [url]"},
+			"roll":  {Command: "roll [dice] [comment]"},
+			"plain": {Command: "plain"},
+		}},
+		"nederlands.yml": {Commands: map[string]config.LanguagePluginCommandDetails{
+			"save": {Command: "bewaar [naam] [url]"},
+		}},
+	}
+	params := map[string][]Param{
+		"save": {{Name: "name", Type: StringParam}, {Name: "url", Type: URLParam}},
+		"roll": {{Name: "dice", Type: IntParam}, {Name: "comment", Type: RestParam, Optional: true}},
+	}
+	var commandTests = []struct {
+		message string
+		command string
+		args    Args
+		usage   string
+	}{
+		{"save cat https://example.com/cat.gif", "save", Args{"name": "cat", "url": "https://example.com/cat.gif"}, ""},
+		{"save cat", "save", nil, "Please provide url\nUsage: *save [name] [url]*"},
+		{"save", "save", nil, "Please provide name\nUsage: *save [name] [url]*"},
+		{"save cat example", "save", nil, "url should be a URL\nUsage: *save [name] [url]*"},
+		{"save cat ftp://example.com/cat.gif", "save", nil, "url should be a URL\nUsage: *save [name] [url]*"},
+		{"bewaar kat nergens", "save", nil, "url moet een URL zijn\nGebruik: *bewaar [naam] [url]*"},
+		{"roll 3", "roll", Args{"dice": "3"}, ""},
+		{"roll 3 for initiative", "roll", Args{"dice": "3", "comment": "for initiative"}, ""},
+		{"roll three", "roll", nil, "dice should be a number\nUsage: *roll [dice] [comment]*"},
+		{"plain", "plain", Args{}, ""},
+	}
+	for _, tt := range commandTests {
+		plugin := HelpPlugin{BasePlugin{
+			name:      "test",
+			request:   slack.Request{Text: tt.message},
+			languages: languages,
+		}}
+		command, err := getCommand(plugin, params)
+		if tt.usage != "" {
+			usageError, ok := err.(*UsageError)
+			if !ok {
+				t.Errorf("%v: expected a usage error, actual %v", tt.message, err)
+				continue
+			}
+			if text := usageError.Response().Text; text != tt.usage {
+				t.Errorf("%v: expected usage %q, actual %q", tt.message, tt.usage, text)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tt.message, err)
+			continue
+		}
+		if command.Name != tt.command || len(command.Args) != len(tt.args) {
+			t.Errorf("%v: expected %v with %v, actual %v with %v", tt.message, tt.command, tt.args, command.Name, command.Args)
+		}
+		for name, value := range tt.args {
+			if command.Args.String(name) != value {
+				t.Errorf("%v: expected %v to be %v, actual %v", tt.message, name, value, command.Args.String(name))
+			}
+		}
+	}
+	plugin := HelpPlugin{BasePlugin{request: slack.Request{Text: "roll 3"}, languages: languages}}
+	if command, _ := getCommand(plugin, params); command.Args.Int("dice") != 3 {
+		t.Error("Expected the number argument to be an int")
+	}
+	plugin.request.Text = "unknown"
+	if _, err := getCommand(plugin, params); err == nil {
+		t.Error("Expected an error for an unknown command")
+	} else if _, ok := err.(*NoMatchError); !ok {
+		t.Errorf("Expected a NoMatchError, actual %v", err)
+	}
+}
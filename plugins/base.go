@@ -0,0 +1,77 @@
+package plugins
+
+import (
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// BasePlugin provides the parts of IgorPlugin that are the same for every
+// plugin: its name, the request, and its language configuration. Plugins
+// embed it and provide Work, and can replace any of its functions.
+type BasePlugin struct {
+	name           string
+	request        slack.Request
+	languages      map[string]config.LanguagePluginDetails
+	chosenLanguage string
+}
+
+// NewBasePlugin creates the BasePlugin for a request. The name is used to
+// look up the plugin in the language files.
+func NewBasePlugin(name string, request slack.Request) BasePlugin {
+	return BasePlugin{
+		name:      name,
+		request:   request,
+		languages: getPluginLanguages(name),
+	}
+}
+
+// Config returns the plugin configuration
+func (plugin BasePlugin) Config() IgorConfig {
+	return plugin
+}
+
+// Languages returns the languages available for the plugin
+func (plugin BasePlugin) Languages() map[string]config.LanguagePluginDetails {
+	return plugin.languages
+}
+
+// ChosenLanguage returns the language active for this plugin
+func (plugin BasePlugin) ChosenLanguage() string {
+	return plugin.chosenLanguage
+}
+
+// SetLanguage sets the language active for this plugin, usually the language
+// of the command that matched
+func (plugin *BasePlugin) SetLanguage(language string) {
+	plugin.chosenLanguage = language
+}
+
+// Name returns the name of the plugin
+func (plugin BasePlugin) Name() string {
+	return plugin.name
+}
+
+// Request returns the request the plugin was created for
+func (plugin BasePlugin) Request() slack.Request {
+	return plugin.request
+}
+
+// Message returns the original message. Commands are matched case
+// insensitively, so the case is kept for values like URLs.
+func (plugin BasePlugin) Message() string {
+	return plugin.request.Text
+}
+
+// Description returns a global description of the plugin
+func (plugin BasePlugin) Description(language string) string {
+	return getDescriptionText(plugin, language)
+}
+
+// Describe provides the triggers the plugin can handle
+func (plugin BasePlugin) Describe(language string) map[string]string {
+	descriptions := make(map[string]string)
+	for _, values := range getAllCommands(plugin, language) {
+		descriptions[values.Command] = values.Description
+	}
+	return descriptions
+}
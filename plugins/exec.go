@@ -0,0 +1,154 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/logging"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// ExecPlugin runs an external program configured in the exec section of the
+// configuration. The program receives the request as JSON on stdin, and
+// writes its response as JSON to stdout.
+type ExecPlugin struct {
+	BasePlugin
+	config  execConfig
+	timeout time.Duration
+}
+
+// execConfig contains the settings of an exec plugin. The triggers are
+// command templates by name, like the commands in the language files.
+type execConfig struct {
+	Command     string
+	Args        []string
+	Description string
+	Triggers    map[string]config.LanguagePluginCommandDetails
+	Timeout     string
+	Env         map[string]string
+	// ShareResponseURL includes the response_url in the request sent to
+	// the program
+	ShareResponseURL bool
+}
+
+func init() {
+	registerSource("exec", execRegistrations)
+}
+
+// execRegistrations creates the registrations for the exec plugins in the
+// configuration
+func execRegistrations() ([]Registration, error) {
+	pluginConfig := struct {
+		Exec map[string]execConfig
+	}{}
+	if err := config.ParseConfig(&pluginConfig); err != nil {
+		return nil, err
+	}
+	registrations := []Registration{}
+	for name, details := range pluginConfig.Exec {
+		name, details := name, details
+		// The program is looked up once, instead of for every request
+		var lookupErr error
+		if details.Command != "" {
+			_, lookupErr = exec.LookPath(details.Command)
+		}
+		registrations = append(registrations, Registration{
+			Name:    name,
+			Section: "exec",
+			Factory: func(request slack.Request) (IgorPlugin, error) {
+				return newExecPlugin(name, details, lookupErr, request)
+			},
+		})
+	}
+	return registrations, nil
+}
+
+// newExecPlugin instantiates an ExecPlugin. Its commands are available in
+// the default language. The lookup error is the result of looking up the
+// program when the plugin was registered.
+func newExecPlugin(name string, details execConfig, lookupErr error, request slack.Request) (IgorPlugin, error) {
+	if details.Command == "" {
+		return ExecPlugin{}, errors.New("no command configured")
+	}
+	if len(details.Triggers) == 0 {
+		return ExecPlugin{}, errors.New("no triggers configured")
+	}
+	if lookupErr != nil {
+		return ExecPlugin{}, lookupErr
+	}
+	plugin := ExecPlugin{
+		BasePlugin: BasePlugin{name: name, request: request},
+		config:     details,
+	}
+	if details.Timeout != "" {
+		timeout, err := time.ParseDuration(details.Timeout)
+		if err != nil {
+			return ExecPlugin{}, err
+		}
+		plugin.timeout = timeout
+	}
+	plugin.languages = triggerLanguages(request, details.Description, details.Triggers)
+	return plugin, nil
+}
+
+// Work runs the program if one of the triggers matches. The values for the
+// placeholders in the trigger are sent as arguments. Empty output means the
+// program can't handle the request after all.
+func (plugin ExecPlugin) Work(ctx context.Context) (slack.Response, error) {
+	response := slack.Response{}
+	command, err := getCommand(plugin, triggerParams(plugin.config.Triggers))
+	if err != nil {
+		return response, err
+	}
+	plugin.chosenLanguage = command.Language
+	stdin, err := json.Marshal(newTriggerInput(plugin.request, command, plugin.config.ShareResponseURL))
+	if err != nil {
+		return response, err
+	}
+	if plugin.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, plugin.timeout)
+		defer cancel()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, plugin.config.Command, plugin.config.Args...)
+	cmd.Env = plugin.environment()
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		logging.FromContext(ctx).With(logging.Fields{"stderr": stderr.String()}).Error("Program "+plugin.config.Command+" failed", err)
+		return response, fmt.Errorf("running %s: %s", plugin.config.Command, err)
+	}
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return response, CreateNoMatchError("No output")
+	}
+	response, err = decodeResponse(stdout.Bytes())
+	if err != nil {
+		return response, fmt.Errorf("parsing the output of %s: %s", plugin.config.Command, err)
+	}
+	return response, nil
+}
+
+// environment returns the environment variables for the program. Only the
+// configured variables are passed on, next to the PATH and HOME, so Igor's
+// own configuration isn't shared.
+func (plugin ExecPlugin) environment() []string {
+	env := []string{}
+	for _, name := range []string{"PATH", "HOME"} {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	for name, value := range plugin.config.Env {
+		env = append(env, name+"="+value)
+	}
+	return env
+}
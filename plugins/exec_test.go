@@ -0,0 +1,135 @@
+package plugins_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/plugins"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// TestExecHelper isn't a real test, it's the program run by the exec plugin
+// in TestExec. It responds with the input it received.
+func TestExecHelper(t *testing.T) {
+	if os.Getenv("IGOR_EXEC_HELPER") != "1" {
+		return
+	}
+	input := struct {
+		Request   slack.Request
+		Command   string
+		Arguments map[string]string
+	}{}
+	json.NewDecoder(os.Stdin).Decode(&input)
+	switch input.Arguments["dice"] {
+	case "fail":
+		fmt.Fprintln(os.Stderr, "Something went wrong")
+		os.Exit(1)
+	case "slow":
+		time.Sleep(5 * time.Second)
+	case "none":
+		os.Exit(0)
+	}
+	fmt.Printf(`{"text": "%s %s for %s", "response_type": "in_channel", "blocks": [{"type": "divider"}]}`,
+		input.Command, input.Arguments["dice"], input.Request.UserName)
+	os.Exit(0)
+}
+
+func TestExec(t *testing.T) {
+	// The helper is this test binary, which can take a while to start on a
+	// slow machine or with the race detector. Only the plugin that checks
+	// the deadline has a short timeout.
+	execConfig := map[string]interface{}{
+		"token":       "testtoken",
+		"languagedir": "../language",
+		"exec": map[string]interface{}{
+			"dice": map[string]interface{}{
+				"command":     os.Args[0],
+				"args":        []string{"-test.run=TestExecHelper"},
+				"description": "Rolls dice",
+				"triggers": map[string]interface{}{
+					"roll": map[string]string{"command": "roll [dice]", "description": "Roll the dice"},
+				},
+				"timeout": "10s",
+				"env":     map[string]string{"IGOR_EXEC_HELPER": "1"},
+			},
+			"slowdice": map[string]interface{}{
+				"command": os.Args[0],
+				"args":    []string{"-test.run=TestExecHelper"},
+				"triggers": map[string]interface{}{
+					"slowroll": map[string]string{"command": "slowroll [dice]", "description": "Roll the dice slowly"},
+				},
+				"timeout": "1s",
+				"env":     map[string]string{"IGOR_EXEC_HELPER": "1"},
+			},
+			"broken": map[string]interface{}{"command": "igor-does-not-exist", "triggers": map[string]interface{}{}},
+		},
+	}
+	encoded, _ := json.Marshal(execConfig)
+	if err := os.Setenv("IGOR_CONFIG", string(encoded)); err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	generalConfig, err := config.GeneralConfig()
+	if err != nil {
+		t.Fatal("Problem getting config")
+	}
+	disabled := make(map[string]bool)
+	for _, diagnostic := range plugins.Diagnostics(generalConfig) {
+		disabled[diagnostic.Plugin] = true
+	}
+	if !disabled["broken"] || disabled["dice"] || disabled["slowdice"] {
+		t.Errorf("Expected only the broken exec plugin to be disabled, actual %v", disabled)
+	}
+
+	request := slack.Request{UserName: "testuser", Text: "roll 2d6"}
+	plugin, ok := plugins.GetPlugins(request, generalConfig)["dice"]
+	if !ok {
+		t.Fatal("Expected the dice plugin to be activated")
+	}
+	if plugin.Description("") != "Rolls dice" || plugin.Describe("")["roll [dice]"] != "Roll the dice" {
+		t.Error("Expected the description and triggers from the configuration")
+	}
+	response, err := plugin.Work(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error", err.Error())
+	}
+	if response.Text != "roll 2d6 for testuser" || response.ResponseType != "in_channel" {
+		t.Errorf("Unexpected response %v", response)
+	}
+
+	var errorTests = []struct {
+		plugin  string
+		text    string
+		noMatch bool
+	}{
+		{"dice", "roll fail", false},
+		{"slowdice", "slowroll slow", false},
+		{"dice", "roll none", true},
+		{"dice", "throw 2d6", true},
+	}
+	for _, tt := range errorTests {
+		request.Text = tt.text
+		plugin := plugins.GetPlugins(request, generalConfig)[tt.plugin]
+		_, err := plugin.Work(context.Background())
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.text)
+			continue
+		}
+		if _, ok := err.(*plugins.NoMatchError); ok != tt.noMatch {
+			t.Errorf("%s: unexpected error %v", tt.text, err)
+		}
+	}
+
+	generalConfig.Blacklist = []string{"dice"}
+	if _, ok := plugins.GetPlugins(request, generalConfig)["dice"]; ok {
+		t.Error("Expected the blacklist to apply to exec plugins")
+	}
+	if strings.Contains(fmt.Sprint(plugins.Diagnostics(generalConfig)), "dice") {
+		t.Error("Expected no diagnostics for blacklisted plugins")
+	}
+}
@@ -2,7 +2,8 @@ package plugins
 
 import (
 	"bytes"
-	"strings"
+	"context"
+	"sort"
 
 	"github.com/ArjenSchwarz/igor/config"
 	"github.com/ArjenSchwarz/igor/slack"
@@ -10,45 +11,21 @@ import (
 
 // HelpPlugin provides help functions
 type HelpPlugin struct {
-	name        string
-	description string
-	request     slack.Request
-	config      helpConfig
+	BasePlugin
 }
 
-// Config returns the plugin configuration
-func (plugin HelpPlugin) Config() IgorConfig {
-	return plugin.config
-}
-
-type helpConfig struct {
-	languages      map[string]config.LanguagePluginDetails
-	chosenLanguage string
-}
-
-// Languages returns the languages available for the plugin
-func (config helpConfig) Languages() map[string]config.LanguagePluginDetails {
-	return config.languages
-}
-
-// ChosenLanguage returns the language active for this plugin
-func (config helpConfig) ChosenLanguage() string {
-	return config.chosenLanguage
+func init() {
+	Register(Registration{
+		Name: "help",
+		Factory: func(request slack.Request) (IgorPlugin, error) {
+			return Help(request), nil
+		},
+	})
 }
 
 // Help instantiates the HelpPlugin
 func Help(request slack.Request) IgorPlugin {
-	pluginName := "help"
-	pluginConfig := helpConfig{
-		languages: getPluginLanguages(pluginName),
-	}
-	plugin := HelpPlugin{
-		name:    pluginName,
-		request: request,
-		config:  pluginConfig,
-	}
-
-	return plugin
+	return HelpPlugin{BasePlugin: NewBasePlugin("help", request)}
 }
 
 // Work parses the request and ensures a request comes through if any triggers
@@ -57,13 +34,13 @@ func Help(request slack.Request) IgorPlugin {
 //  * help
 //  * introduce yourself
 //  * tell me about yourself
-func (plugin HelpPlugin) Work() (slack.Response, error) {
+func (plugin HelpPlugin) Work(ctx context.Context) (slack.Response, error) {
 	response := slack.Response{}
 	message, language := getCommandName(plugin)
-	plugin.config.chosenLanguage = language
+	plugin.chosenLanguage = language
 	switch message {
 	case "help":
-		tmpresponse, err := plugin.handleHelp(response)
+		tmpresponse, err := plugin.handleHelp(ctx, response)
 		if err != nil {
 			return tmpresponse, err
 		}
@@ -81,49 +58,68 @@ func (plugin HelpPlugin) Work() (slack.Response, error) {
 	return response, nil
 }
 
-// Describe provides the triggers HelpPlugin can handle
-func (plugin HelpPlugin) Describe(language string) map[string]string {
-	descriptions := make(map[string]string)
-
-	for _, values := range getAllCommands(plugin, language) {
-		descriptions[values.Command] = values.Description
-	}
-	return descriptions
-}
-
-func (plugin HelpPlugin) handleHelp(response slack.Response) (slack.Response, error) {
+func (plugin HelpPlugin) handleHelp(ctx context.Context, response slack.Response) (slack.Response, error) {
 	commandDetails := getCommandDetails(plugin, "help")
 	response.Text = commandDetails.Texts["response_text"]
-	config, err := config.GeneralConfig()
+	config, err := config.TeamConfig(plugin.request.TeamID)
 	if err != nil {
 		return response, err
 	}
+	response.AddBlock(slack.NewSectionBlock(slack.Markdown(response.Text)))
 	attach := slack.Attachment{Text: ""}
 	allPlugins := GetPlugins(plugin.request, config)
 	for languageName, details := range config.Languages {
-		if languageName != plugin.config.chosenLanguage {
+		if languageName != plugin.chosenLanguage {
 			attach.Text += details.Language["description"] + "\n"
 		}
 	}
 	if attach.Text != "" {
 		response.AddAttachment(attach)
+		response.AddBlock(slack.NewContextBlock(slack.Markdown(attach.Text)))
+	}
+	type pluginHelp struct {
+		attach slack.Attachment
+		menus  []*slack.SectionBlock
 	}
-	c := make(chan slack.Attachment)
-	for _, igor := range allPlugins {
-		go func(igor IgorPlugin, language string) {
+	c := make(chan pluginHelp)
+	for name, igor := range allPlugins {
+		go func(name string, igor IgorPlugin, language string) {
 			var buffer bytes.Buffer
-			for command, description := range igor.Describe(language) {
+			descriptions := describeAllowed(ctx, name, igor, language, plugin.request, config)
+			commands := make([]string, 0, len(descriptions))
+			for command := range descriptions {
+				commands = append(commands, command)
+			}
+			sort.Strings(commands)
+			// Slack allows a limited number of fields in a section, so
+			// plugins with more commands continue in the next section
+			menus := []*slack.SectionBlock{slack.NewSectionBlock(slack.Markdown("*" + igor.Description(language) + "*"))}
+			for _, command := range commands {
+				description := descriptions[command]
 				buffer.WriteString("- *" + command + "*: " + description + "\n")
+				menu := menus[len(menus)-1]
+				if len(menu.Fields) == slack.MaxSectionFields {
+					menu = slack.NewSectionBlock(nil)
+					menus = append(menus, menu)
+				}
+				menu.AddField(slack.Markdown("*" + command + "*\n" + description))
 			}
 			attach := slack.Attachment{}
 			attach.Title = igor.Description(language)
 			attach.Text = buffer.String()
 			attach.EnableMarkdownFor("text")
-			c <- attach
-		}(igor, plugin.config.chosenLanguage)
+			c <- pluginHelp{attach: attach, menus: menus}
+		}(name, igor, plugin.chosenLanguage)
 	}
 	for i := 0; i < len(allPlugins); i++ {
-		response.AddAttachment(<-c)
+		help := <-c
+		response.AddAttachment(help.attach)
+		if len(help.menus[0].Fields) > 0 {
+			response.AddBlock(slack.NewDividerBlock())
+			for _, menu := range help.menus {
+				response.AddBlock(menu)
+			}
+		}
 	}
 	return response, nil
 }
@@ -169,18 +165,3 @@ func (plugin HelpPlugin) handleWhoAmI(response slack.Response) slack.Response {
 	response.AddAttachment(attach)
 	return response
 }
-
-// Description returns a global description of the plugin
-func (plugin HelpPlugin) Description(language string) string {
-	return getDescriptionText(plugin, language)
-}
-
-// Name returns the name of the plugin
-func (plugin HelpPlugin) Name() string {
-	return plugin.name
-}
-
-// Message returns a formatted version of the original message
-func (plugin HelpPlugin) Message() string {
-	return strings.ToLower(plugin.request.Text)
-}
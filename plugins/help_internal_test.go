@@ -4,7 +4,7 @@ import "testing"
 
 func TestName(t *testing.T) {
 	expectedResult := "testname"
-	plugin := HelpPlugin{name: expectedResult}
+	plugin := HelpPlugin{BasePlugin{name: expectedResult}}
 	if plugin.Name() != expectedResult {
 		t.Error("Name method not working correctly")
 	}
@@ -1,7 +1,11 @@
 package plugins_test
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/ArjenSchwarz/igor/plugins"
@@ -53,14 +57,14 @@ func TestWork(t *testing.T) {
 	plugin := plugins.Help(request)
 	// No result test
 	request.Text = "fail"
-	_, err = plugin.Work()
+	_, err = plugin.Work(context.Background())
 	if err == nil {
 		t.Error("Expected failure")
 	}
 	// Help call, lowercase
 	request.Text = "help"
 	plugin = plugins.Help(request)
-	response, err := plugin.Work()
+	response, err := plugin.Work(context.Background())
 	if err != nil {
 		t.Error("Unexpected error for help", err.Error())
 	}
@@ -70,6 +74,9 @@ func TestWork(t *testing.T) {
 	if len(response.Attachments) == 0 {
 		t.Error("Expected attachments")
 	}
+	if len(response.Blocks) == 0 {
+		t.Error("Expected blocks")
+	}
 	if response.IsPublic() {
 		t.Error("Help should not give a public response")
 	}
@@ -77,7 +84,7 @@ func TestWork(t *testing.T) {
 	// Help call, mixed case
 	request.Text = "Help"
 	plugin = plugins.Help(request)
-	response, err = plugin.Work()
+	response, err = plugin.Work(context.Background())
 	if err != nil {
 		t.Error("Unexpected error for help")
 	}
@@ -88,7 +95,7 @@ func TestWork(t *testing.T) {
 	// Introduce yourself call
 	request.Text = "introduce yourself"
 	plugin = plugins.Help(request)
-	response, err = plugin.Work()
+	response, err = plugin.Work(context.Background())
 	if err != nil {
 		t.Error("Unexpected error for help")
 	}
@@ -102,3 +109,48 @@ func TestWork(t *testing.T) {
 		t.Error("Introduce yourself should not give a public response")
 	}
 }
+
+func TestHelpManyCommands(t *testing.T) {
+	triggers := make(map[string]interface{})
+	for i := 1; i <= 25; i++ {
+		name := fmt.Sprintf("trick%02d", i)
+		triggers[name] = map[string]string{"command": name, "description": "Does trick " + name}
+	}
+	helpConfig := map[string]interface{}{
+		"token":       "testtoken",
+		"languagedir": "../language",
+		"exec": map[string]interface{}{
+			"tricks": map[string]interface{}{
+				"command":     os.Args[0],
+				"description": "Performs tricks",
+				"triggers":    triggers,
+			},
+		},
+	}
+	encoded, _ := json.Marshal(helpConfig)
+	if err := os.Setenv("IGOR_CONFIG", string(encoded)); err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	response, err := plugins.Help(slack.Request{Text: "help"}).Work(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error for help", err)
+	}
+	fields := ""
+	for _, block := range response.Blocks {
+		section, ok := block.(*slack.SectionBlock)
+		if !ok {
+			continue
+		}
+		if len(section.Fields) > slack.MaxSectionFields {
+			t.Errorf("Expected at most %d fields in a section, actual %d", slack.MaxSectionFields, len(section.Fields))
+		}
+		for _, field := range section.Fields {
+			fields += field.Text + "\n"
+		}
+	}
+	for name := range triggers {
+		if !strings.Contains(fields, "*"+name+"*") {
+			t.Errorf("Expected %s in the help blocks", name)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package plugins_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -35,7 +36,7 @@ func TestNaughtyStrings(t *testing.T) {
 	for _, plugin := range plugins.GetPlugins(request, config) {
 		t.Run("Plugin="+plugin.Name(), func(t *testing.T) {
 			for _, string := range list {
-				_, err := plugin.Work()
+				_, err := plugin.Work(context.Background())
 				if err != nil {
 					switch err.(type) {
 					case *plugins.NoMatchError:
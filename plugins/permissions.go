@@ -0,0 +1,197 @@
+package plugins
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/logging"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// PermissionError indicates the user doesn't have the permission needed for
+// a command
+type PermissionError struct {
+	Plugin     string
+	Command    string
+	Permission string
+	Message    string
+}
+
+// Error returns a string interpretation of the PermissionError
+func (e *PermissionError) Error() string {
+	return "Permission " + e.Permission + " needed for " + e.Plugin + "." + e.Command
+}
+
+// Response returns the response telling the user they're not allowed to use
+// the command
+func (e *PermissionError) Response() slack.Response {
+	return slack.Response{Text: e.Message}
+}
+
+// CheckPermission checks if the user who sent the request has the permission
+// needed for the matching command, if it needs one. It returns a
+// PermissionError with the text in the language of the command otherwise,
+// which is the command's own forbidden text if it has one.
+func CheckPermission(ctx context.Context, request slack.Request, match Match, config config.Config) error {
+	route := match.Route
+	permission := requiredPermission(route, config)
+	if permission == "" || hasPermission(ctx, request, route.Plugin, permission, config) {
+		return nil
+	}
+	message := getAllCommands(match.Plugin, route.Language)[route.Command].Texts["forbidden"]
+	if message == "" {
+		message = strings.Replace(usageText("forbidden", route.Language, config), "[replace]", route.Template, 1)
+	}
+	return &PermissionError{
+		Plugin:     route.Plugin,
+		Command:    route.Command,
+		Permission: permission,
+		Message:    message,
+	}
+}
+
+// permitted checks if the user who sent the request can use the command of
+// the route
+func permitted(ctx context.Context, route Route, request slack.Request, config config.Config) bool {
+	permission := requiredPermission(route, config)
+	return permission == "" || hasPermission(ctx, request, route.Plugin, permission, config)
+}
+
+// requiredPermission returns the permission needed for the command of the
+// route. The configuration can set it for a command as "plugin.command" or
+// for all commands of a plugin, and takes precedence over the permissions
+// the plugin declares. An empty permission means anyone can use it.
+func requiredPermission(route Route, config config.Config) string {
+	for _, name := range []string{route.Plugin + "." + route.Command, route.Plugin} {
+		if permission, ok := config.Permissions[name]; ok {
+			return permission
+		}
+	}
+	if registration, ok := findRegistration(route.Plugin); ok {
+		return registration.Permissions[route.Command]
+	}
+	return ""
+}
+
+// hasPermission checks if the user is a member of a role that grants the
+// permission. These are the configured roles and those the plugin defines
+// in its own settings.
+func hasPermission(ctx context.Context, request slack.Request, plugin string, permission string, config config.Config) bool {
+	for _, role := range config.Roles {
+		if role.Grants(permission) && isMember(ctx, request, role, config) {
+			return true
+		}
+	}
+	registration, ok := findRegistration(plugin)
+	if !ok || registration.Roles == nil {
+		return false
+	}
+	roles, err := registration.Roles(request.TeamID)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to load the roles of "+plugin, err)
+		return false
+	}
+	for _, role := range roles {
+		if role.Grants(permission) && isMember(ctx, request, role, config) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMember checks if the user is one of the role's members, or in one of
+// its user groups. Groups that can't be retrieved are skipped.
+func isMember(ctx context.Context, request slack.Request, role config.Role, config config.Config) bool {
+	for _, member := range role.Members {
+		if matchesMember(request, member) {
+			return true
+		}
+	}
+	if request.UserID == "" || !fromSlack(request) {
+		return false
+	}
+	for _, group := range role.Groups {
+		members, err := groupMembers(request.TeamID, group, config)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to retrieve the members of user group "+group, err)
+			continue
+		}
+		if contains(members, request.UserID) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMember checks if the member is the user who sent the request.
+// Members of other platforms than Slack are written with their platform,
+// like "telegram:12345", and are only matched by their ID. Names can be
+// chosen freely on some platforms, so a user elsewhere can't claim the role
+// of a Slack user with the same name.
+func matchesMember(request slack.Request, member string) bool {
+	if parts := strings.SplitN(member, ":", 2); len(parts) == 2 {
+		return parts[0] == request.Platform && parts[1] != "" && parts[1] == request.UserID
+	}
+	if !fromSlack(request) {
+		return false
+	}
+	return member == request.UserID || (request.UserName != "" && member == request.UserName)
+}
+
+// fromSlack checks if the request was sent through Slack, which is the
+// platform for requests that don't have one
+func fromSlack(request slack.Request) bool {
+	return request.Platform == "" || request.Platform == "slack"
+}
+
+// groupCacheDuration is how long the members of a user group are kept
+// before they're retrieved again
+const groupCacheDuration = 5 * time.Minute
+
+type cachedGroup struct {
+	members   []string
+	retrieved time.Time
+}
+
+var (
+	groupCache = make(map[string]cachedGroup)
+	groupLock  sync.Mutex
+)
+
+// groupMembers returns the IDs of the users in the team's user group. They
+// are cached, so the Slack API isn't called for every request.
+func groupMembers(teamID string, group string, config config.Config) ([]string, error) {
+	key := teamID + ":" + group
+	groupLock.Lock()
+	cached, ok := groupCache[key]
+	groupLock.Unlock()
+	if ok && time.Since(cached.retrieved) < groupCacheDuration {
+		return cached.members, nil
+	}
+	members, err := slack.UserGroupMembers(config.BotToken, group)
+	if err != nil {
+		return nil, err
+	}
+	groupLock.Lock()
+	groupCache[key] = cachedGroup{members: members, retrieved: time.Now()}
+	groupLock.Unlock()
+	return members, nil
+}
+
+// findRegistration returns the registration of the plugin, whether it's
+// defined in code or in the configuration
+func findRegistration(name string) (Registration, bool) {
+	if registration, ok := registry[name]; ok {
+		return registration, true
+	}
+	all, _ := registrations()
+	for _, registration := range all {
+		if registration.Name == name {
+			return registration, true
+		}
+	}
+	return Registration{}, false
+}
@@ -0,0 +1,141 @@
+package plugins_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/plugins"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// rememberConfig activates the remember plugin, with U1 as its admin
+const rememberConfig = `{"token": "testtoken", "languagedir": "../language",
+	"remember": {"dynamodb": "igorRemember", "admins": ["U1"]}}`
+
+// match finds the best matching command for the text
+func match(t *testing.T, request slack.Request, generalConfig config.Config) plugins.Match {
+	matches := plugins.MatchingPlugins(request, generalConfig)
+	if len(matches) == 0 {
+		t.Fatalf("Expected a match for %v", request.Text)
+	}
+	return matches[0]
+}
+
+func TestCheckPermission(t *testing.T) {
+	err := os.Setenv("IGOR_CONFIG", routerConfig)
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	generalConfig, err := config.GeneralConfig()
+	if err != nil {
+		t.Fatal("Problem getting config")
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/usergroups.users.list" || r.FormValue("usergroup") != "S0FUN" {
+			fmt.Fprint(w, `{"ok": false, "error": "no_such_subteam"}`)
+			return
+		}
+		fmt.Fprint(w, `{"ok": true, "users": ["U3"]}`)
+	}))
+	defer api.Close()
+	defer func(url string) { slack.APIURL = url }(slack.APIURL)
+	slack.APIURL = api.URL + "/"
+	generalConfig.Permissions = map[string]string{
+		"xkcd.xkcd_random": "fun",
+		"status":           "ops",
+	}
+	generalConfig.Roles = map[string]config.Role{
+		"jokers":   {Members: []string{"U1", "telegram:42"}, Groups: []string{"S0FUN", "S0GONE"}, Permissions: []string{"fun"}},
+		"operator": {Members: []string{"arjen"}, Permissions: []string{"*"}},
+	}
+	var permissionTests = []struct {
+		text    string
+		request slack.Request
+		allowed bool
+	}{
+		{"xkcd", slack.Request{UserID: "U2"}, true},
+		{"xkcd random", slack.Request{UserID: "U2"}, false},
+		{"xkcd random", slack.Request{UserID: "U1"}, true},
+		{"xkcd random", slack.Request{UserID: "U3"}, true},
+		{"xkcd random", slack.Request{UserID: "U4", UserName: "arjen"}, true},
+		{"status aws", slack.Request{UserID: "U1"}, false},
+		{"status aws", slack.Request{UserName: "arjen"}, true},
+		{"xkcd random", slack.Request{UserID: "U1", Platform: "slack"}, true},
+		{"xkcd random", slack.Request{UserID: "U1", Platform: "telegram"}, false},
+		{"xkcd random", slack.Request{UserID: "42", Platform: "telegram"}, true},
+		{"xkcd random", slack.Request{UserID: "42", Platform: "discord"}, false},
+		{"status aws", slack.Request{UserID: "99", UserName: "arjen", Platform: "discord"}, false},
+	}
+	for _, tt := range permissionTests {
+		request := tt.request
+		request.Text = tt.text
+		err := plugins.CheckPermission(context.Background(), request, match(t, request, generalConfig), generalConfig)
+		if tt.allowed && err != nil {
+			t.Errorf("%v by %v: expected to be allowed, actual %v", tt.text, request.UserID, err)
+		}
+		if !tt.allowed {
+			permissionErr, ok := err.(*plugins.PermissionError)
+			if !ok {
+				t.Fatalf("%v by %v: expected a PermissionError, actual %v", tt.text, request.UserID, err)
+			}
+			if !strings.Contains(permissionErr.Response().Text, "aren't allowed") {
+				t.Errorf("%v: expected a denial, actual %q", tt.text, permissionErr.Response().Text)
+			}
+		}
+	}
+	request := slack.Request{UserID: "U2", Text: "xkcd willekeurig"}
+	err = plugins.CheckPermission(context.Background(), request, match(t, request, generalConfig), generalConfig)
+	if permissionErr, ok := err.(*plugins.PermissionError); !ok || permissionErr.Response().Text != "U mag *xkcd willekeurig* niet gebruiken" {
+		t.Errorf("Expected a denial in the language of the command, actual %v", err)
+	}
+}
+
+func TestRememberAdmins(t *testing.T) {
+	err := os.Setenv("IGOR_CONFIG", rememberConfig)
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	generalConfig, err := config.GeneralConfig()
+	if err != nil {
+		t.Fatal("Problem getting config")
+	}
+	admin := slack.Request{UserID: "U1", Text: "forget cat"}
+	if err := plugins.CheckPermission(context.Background(), admin, match(t, admin, generalConfig), generalConfig); err != nil {
+		t.Errorf("Expected the admin to be allowed to forget, actual %v", err)
+	}
+	user := slack.Request{UserID: "U2", Text: "forget cat"}
+	err = plugins.CheckPermission(context.Background(), user, match(t, user, generalConfig), generalConfig)
+	if permissionErr, ok := err.(*plugins.PermissionError); !ok || permissionErr.Response().Text != "You aren't allowed to make Igor forget something" {
+		t.Errorf("Expected the forget command's own denial, actual %v", err)
+	}
+	for _, tt := range []struct {
+		user   string
+		listed bool
+	}{{"U1", true}, {"U2", false}} {
+		response, err := plugins.Help(slack.Request{UserID: tt.user, Text: "help"}).Work(context.Background())
+		if err != nil {
+			t.Fatal("Unexpected error for help", err)
+		}
+		listed := false
+		for _, attachment := range response.Attachments {
+			listed = listed || strings.Contains(attachment.Text, "forget [name]")
+		}
+		if listed != tt.listed {
+			t.Errorf("Help for %v: expected forget listed %v, actual %v", tt.user, tt.listed, listed)
+		}
+	}
+	plugin, err := plugins.Remember(user)
+	if err != nil {
+		t.Fatal("Unexpected error creating the plugin", err)
+	}
+	_, err = plugin.(plugins.IgorActionPlugin).HandleAction(context.Background(), slack.Action{Name: "forget", Value: "cat"})
+	if permissionErr, ok := err.(*plugins.PermissionError); !ok || permissionErr.Response().Text != "You aren't allowed to make Igor forget something" {
+		t.Errorf("Expected the confirmation to be refused with a PermissionError, actual %v", err)
+	}
+}
@@ -1,16 +1,22 @@
 package plugins
 
 import (
-	"regexp"
-	"strings"
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
 
 	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/metrics"
 	"github.com/ArjenSchwarz/igor/slack"
 )
 
-// IgorPlugin is the interface that needs to be followed by all plugins
+// IgorPlugin is the interface that needs to be followed by all plugins. Work
+// receives a context that is cancelled when the plugin's deadline passes, and
+// should be used for any outgoing calls.
 type IgorPlugin interface {
-	Work() (slack.Response, error)
+	Work(ctx context.Context) (slack.Response, error)
 	Describe(string) map[string]string
 	Name() string
 	Description(string) string
@@ -18,44 +24,67 @@ type IgorPlugin interface {
 	Config() IgorConfig
 }
 
+// IgorActionPlugin is the interface for plugins with interactive components.
+// Actions added to a response with the plugin's name as callback are sent
+// back to the plugin's HandleAction function when they are clicked.
+type IgorActionPlugin interface {
+	IgorPlugin
+	HandleAction(ctx context.Context, action slack.Action) (slack.Response, error)
+}
+
 // IgorConfig is the interface for all plugin Configuration
 type IgorConfig interface {
 	Languages() map[string]config.LanguagePluginDetails
 	ChosenLanguage() string
 }
 
+// configurable is anything with a plugin configuration, like a plugin or
+// the BasePlugin it embeds
+type configurable interface {
+	Config() IgorConfig
+}
+
 // GetPlugins retrieves all the plugins that are activated. It checks the
-// config for a whitelist and blacklist as well.
+// config for a whitelist and blacklist as well, and the policy of the
+// channel the request was sent in. Plugins that can't be created are left
+// out, these are reported by Diagnostics.
 func GetPlugins(request slack.Request, config config.Config) map[string]IgorPlugin {
 	plugins := make(map[string]IgorPlugin)
-	plugins["help"] = Help(request)
-	//TODO should handle these errors somehow. Returning an error when the
-	//plugin isn't called doesn't make a lot of sense though
-	plugins["weather"], _ = Weather(request)
-	plugins["tumblr"], _ = RandomTumblr(request)
-	plugins["status"], _ = Status(request)
-	plugins["xkcd"], _ = Xkcd(request)
-	plugins["remember"], _ = Remember(request)
-
-	// Whitelist plugins
-	if config.Whitelist != nil {
-		whitelist := make(map[string]IgorPlugin)
-		whitelist["help"] = Help(request) //Help is always required
-		for _, allowedPlugin := range config.Whitelist {
-			whitelist[allowedPlugin] = plugins[allowedPlugin]
+	all, _ := registrations()
+	for _, registration := range all {
+		if !activated(registration.Name, config) || !pluginAllowed(registration.Name, request, config) {
+			continue
+		}
+		plugin, err := registration.create(request)
+		if err != nil {
+			continue
 		}
-		plugins = whitelist
+		plugins[registration.Name] = plugin
 	}
+	return plugins
+}
 
-	// Blacklist plugins
-	if config.Blacklist != nil {
-		for _, pluginname := range config.Blacklist {
-			if pluginname != "help" { // Help is always required
-				delete(plugins, pluginname)
-			}
+// GetActionPlugin retrieves the activated plugin that handles the actions
+// for the provided callback, if the action can be used in the channel the
+// request was sent in. The route has the plugin's name in the configuration,
+// and the command the action belongs to or otherwise the action's name.
+func GetActionPlugin(request slack.Request, config config.Config, callback string, action slack.Action) (IgorActionPlugin, Route, bool) {
+	for name, plugin := range GetPlugins(request, config) {
+		if plugin == nil || plugin.Name() != callback {
+			continue
+		}
+		route := Route{Plugin: name, Command: action.Name}
+		if command, ok := actionCommand(name, action.Name); ok {
+			route.Command = command
+		}
+		if !actionAllowed(name, action.Name, request, config) {
+			return nil, route, false
+		}
+		if actionPlugin, ok := plugin.(IgorActionPlugin); ok {
+			return actionPlugin, route, true
 		}
 	}
-	return plugins
+	return nil, Route{Plugin: callback, Command: action.Name}, false
 }
 
 // NoMatchError is an error type to indicate a plugin didn't find a match
@@ -73,64 +102,63 @@ func CreateNoMatchError(message string) *NoMatchError {
 	return &NoMatchError{Message: message}
 }
 
+// Handles checks if the plugin has a command that matches the request,
+// without doing the actual work
+func Handles(plugin IgorPlugin) bool {
+	name, _ := getCommandName(plugin)
+	return name != ""
+}
+
+// getCommandName returns the name of the plugin's command that best matches
+// the message, and the language it's in. This is for plugins with commands
+// without parameters, others should use getCommand.
 func getCommandName(plugin IgorPlugin) (string, string) {
-	// It's possible for a command to have substitutions
-	// Therefore, this needs to be taken into account
-	reMain := regexp.MustCompile("([^\\[]+) \\[")
-	reCommand := regexp.MustCompile("^([^ ]*) ?")
-	subCommandArray := reCommand.FindStringSubmatch(plugin.Message())
-	subCommand := ""
-	softmatch := make(map[string]string)
-	if subCommandArray != nil {
-		subCommand = strings.ToLower(subCommandArray[1])
-	}
-	for language, details := range plugin.Config().Languages() {
-		for name, value := range details.Commands {
-			matchArray := reMain.FindStringSubmatch(value.Command)
-			match := ""
-			if matchArray != nil {
-				match = strings.ToLower(matchArray[1])
-			}
-			if strings.ToLower(plugin.Message()) == strings.ToLower(value.Command) {
-				return name, language
-			} else if match != "" && match == subCommand {
-				softmatch[language] = name
-			}
-		}
+	command, err := getCommand(plugin, nil)
+	if err != nil {
+		return "", ""
 	}
-	if len(softmatch) > 0 {
-		for language, name := range softmatch {
-			return name, language
-		}
-	}
-	return "", ""
+	return command.Name, command.Language
 }
 
-func getCommandDetails(plugin IgorPlugin, commandName string) config.LanguagePluginCommandDetails {
+func getCommandDetails(plugin configurable, commandName string) config.LanguagePluginCommandDetails {
 	return getAllCommands(plugin, "")[commandName]
 }
 
-func getAllCommands(plugin IgorPlugin, language string) map[string]config.LanguagePluginCommandDetails {
+func getAllCommands(plugin configurable, language string) map[string]config.LanguagePluginCommandDetails {
 	language = getPluginLanguage(plugin, language)
 	return plugin.Config().Languages()[language].Commands
 }
 
-func getDescriptionText(plugin IgorPlugin, language string) string {
+func getDescriptionText(plugin configurable, language string) string {
 	language = getPluginLanguage(plugin, language)
 	return plugin.Config().Languages()[language].Description
 }
 
-func getPluginLanguage(plugin IgorPlugin, language string) string {
+func getPluginLanguage(plugin configurable, language string) string {
 	if language == "" {
 		language = plugin.Config().ChosenLanguage()
 	}
 	if _, ok := plugin.Config().Languages()[language]; !ok {
-		generalConfig, _ := config.GeneralConfig()
-		language = generalConfig.DefaultLanguage
+		language = teamConfig(plugin).DefaultLanguage
 	}
 	return language
 }
 
+// teamConfig returns the configuration for the team the plugin's request
+// came from
+func teamConfig(plugin configurable) config.Config {
+	teamConfig, _ := config.TeamConfig(pluginRequest(plugin).TeamID)
+	return teamConfig
+}
+
+// pluginRequest returns the request the plugin was created for
+func pluginRequest(plugin configurable) slack.Request {
+	if requester, ok := plugin.Config().(interface{ Request() slack.Request }); ok {
+		return requester.Request()
+	}
+	return slack.Request{}
+}
+
 func getPluginLanguages(pluginname string) map[string]config.LanguagePluginDetails {
 	generalConfig, _ := config.GeneralConfig()
 	details := make(map[string]config.LanguagePluginDetails)
@@ -141,3 +169,14 @@ func getPluginLanguages(pluginname string) map[string]config.LanguagePluginDetai
 	}
 	return details
 }
+
+// httpClient is the client used for calling other services, which records
+// their latency and status. Plugins use it through fetch, which adds the
+// settings of the upstream.
+var httpClient = &http.Client{Transport: metrics.Transport{}}
+
+// awsSession creates a session for the AWS services, which uses the
+// httpClient so its requests are recorded as well
+func awsSession() (*session.Session, error) {
+	return session.NewSession(&aws.Config{HTTPClient: httpClient})
+}
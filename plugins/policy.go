@@ -0,0 +1,151 @@
+package plugins
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// channelPolicy returns the policy for the channel the request was sent in.
+// Channels are configured by their ID or by their name, with or without #.
+// When a channel is configured more than once, its ID comes first, then its
+// name, and then its name with #.
+func channelPolicy(request slack.Request, config config.Config) (policy config.ChannelPolicy) {
+	for _, channel := range []string{request.ChannelID, request.ChannelName, "#" + request.ChannelName} {
+		if channel == "" || channel == "#" {
+			continue
+		}
+		if channelPolicy, ok := config.Channels[channel]; ok {
+			return channelPolicy
+		}
+	}
+	return policy
+}
+
+// isChannel checks if the channel is the one the request was sent in
+func isChannel(channel string, request slack.Request) bool {
+	channel = strings.TrimPrefix(channel, "#")
+	return channel != "" && (channel == request.ChannelID || channel == request.ChannelName)
+}
+
+// inChannels checks if the request was sent in one of the channels
+func inChannels(channels []string, request slack.Request) bool {
+	for _, channel := range channels {
+		if isChannel(channel, request) {
+			return true
+		}
+	}
+	return false
+}
+
+// pluginAllowed checks if the plugin, or any of its commands, can be used in
+// the channel the request was sent in. Help is always allowed.
+func pluginAllowed(name string, request slack.Request, config config.Config) bool {
+	if name == "help" {
+		return true
+	}
+	policy := channelPolicy(request, config)
+	if policy.Whitelist != nil && !contains(policy.Whitelist, name) && !containsCommandOf(policy.Whitelist, name) {
+		return false
+	}
+	if contains(policy.Blacklist, name) {
+		return false
+	}
+	if channels, ok := config.Restrictions[name]; ok && !inChannels(channels, request) {
+		return false
+	}
+	return true
+}
+
+// commandAllowed checks if the command of the route can be used in the
+// channel the request was sent in
+func commandAllowed(route Route, request slack.Request, config config.Config) bool {
+	if !pluginAllowed(route.Plugin, request, config) {
+		return false
+	}
+	if route.Plugin == "help" {
+		return true
+	}
+	command := route.Plugin + "." + route.Command
+	policy := channelPolicy(request, config)
+	if policy.Whitelist != nil && !contains(policy.Whitelist, route.Plugin) && !contains(policy.Whitelist, command) {
+		return false
+	}
+	if contains(policy.Blacklist, command) {
+		return false
+	}
+	if channels, ok := config.Restrictions[command]; ok && !inChannels(channels, request) {
+		return false
+	}
+	return true
+}
+
+// actionAllowed checks if the action of the plugin, by its registered name,
+// can be used in the channel the request was sent in. Actions that belong to
+// a command follow the policies for that command, other actions those for
+// the plugin.
+func actionAllowed(plugin string, action string, request slack.Request, config config.Config) bool {
+	if command, ok := actionCommand(plugin, action); ok {
+		return commandAllowed(Route{Plugin: plugin, Command: command}, request, config)
+	}
+	return pluginAllowed(plugin, request, config)
+}
+
+// actionCommand returns the command the action of the plugin belongs to, if
+// the plugin registered one for it
+func actionCommand(plugin string, action string) (string, bool) {
+	registration, ok := findRegistration(plugin)
+	if !ok {
+		return "", false
+	}
+	command, ok := registration.Actions[action]
+	return command, ok
+}
+
+// containsCommandOf checks if the list contains a command of the plugin
+func containsCommandOf(list []string, plugin string) bool {
+	for _, item := range list {
+		if strings.HasPrefix(item, plugin+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// ForcedEphemeral checks if responses in the channel the request was sent in
+// should only be shown to the user who asked
+func ForcedEphemeral(request slack.Request, config config.Config) bool {
+	return channelPolicy(request, config).Ephemeral
+}
+
+// describeAllowed provides the triggers of the plugin that can be used in
+// the channel the request was sent in, by the user who sent it. The
+// descriptions of commands that aren't allowed are left out, including
+// those a plugin adds for its configuration, like a command for every blog.
+func describeAllowed(ctx context.Context, name string, plugin IgorPlugin, language string, request slack.Request, config config.Config) map[string]string {
+	descriptions := plugin.Describe(language)
+	language = getPluginLanguage(plugin, language)
+	routes := []Route{}
+	templates := make(map[string]bool)
+	for _, route := range pluginRoutes(name, plugin) {
+		if route.Language == language {
+			routes = append(routes, route)
+			templates[route.Template] = true
+		}
+	}
+	for _, route := range routes {
+		if commandAllowed(route, request, config) && permitted(ctx, route, request, config) {
+			continue
+		}
+		for trigger := range descriptions {
+			// Triggers that are templates themselves belong to their own
+			// command, others are matched like a message
+			if trigger == route.Template || (!templates[trigger] && route.pattern.MatchString(trigger)) {
+				delete(descriptions, trigger)
+			}
+		}
+	}
+	return descriptions
+}
@@ -0,0 +1,110 @@
+package plugins_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/plugins"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestChannelPolicies(t *testing.T) {
+	err := os.Setenv("IGOR_CONFIG", routerConfig)
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	generalConfig, err := config.GeneralConfig()
+	if err != nil {
+		t.Fatal("Problem getting config")
+	}
+	generalConfig.Channels = map[string]config.ChannelPolicy{
+		"#incidents": {Blacklist: []string{"tumblr", "xkcd.xkcd_specific"}},
+		"C0STATUS":   {Whitelist: []string{"status", "xkcd.xkcd_random"}},
+		"C0INCIDENT": {Whitelist: []string{"tumblr"}},
+	}
+	generalConfig.Restrictions = map[string][]string{
+		"weather":               {"weather"},
+		"status.status_service": {"#ops"},
+	}
+	var policyTests = []struct {
+		text    string
+		channel slack.Request
+		plugin  string
+		command string
+	}{
+		{"tumblr devops", slack.Request{ChannelName: "random"}, "tumblr", "specifictumblr"},
+		{"tumblr devops", slack.Request{ChannelName: "incidents"}, "", ""},
+		{"tumblr devops", slack.Request{ChannelID: "C0INCIDENT", ChannelName: "incidents"}, "tumblr", "specifictumblr"},
+		{"xkcd", slack.Request{ChannelName: "incidents"}, "xkcd", "xkcd"},
+		{"xkcd 327", slack.Request{ChannelName: "incidents"}, "", ""},
+		{"xkcd random", slack.Request{ChannelID: "C0STATUS"}, "xkcd", "xkcd_random"},
+		{"xkcd 327", slack.Request{ChannelID: "C0STATUS"}, "", ""},
+		{"status", slack.Request{ChannelID: "C0STATUS"}, "status", "status"},
+		{"status github", slack.Request{ChannelName: "random"}, "status", "status_url"},
+		{"status github", slack.Request{ChannelName: "ops"}, "status", "status_service"},
+		{"weather", slack.Request{ChannelName: "random"}, "", ""},
+		{"weather", slack.Request{ChannelName: "weather"}, "weather", "weather"},
+		{"help", slack.Request{ChannelID: "C0STATUS"}, "help", "help"},
+	}
+	for _, tt := range policyTests {
+		request := tt.channel
+		request.Text = tt.text
+		matches := plugins.MatchingPlugins(request, generalConfig)
+		plugin, command := "", ""
+		if len(matches) != 0 {
+			plugin, command = matches[0].Route.Plugin, matches[0].Route.Command
+		}
+		if plugin != tt.plugin || command != tt.command {
+			t.Errorf("%v in %v%v: expected %q/%q, actual %q/%q", tt.text, tt.channel.ChannelName, tt.channel.ChannelID,
+				tt.plugin, tt.command, plugin, command)
+		}
+	}
+	if _, ok := plugins.GetPlugins(slack.Request{ChannelName: "incidents"}, generalConfig)["tumblr"]; ok {
+		t.Error("Expected tumblr to be left out in a channel where it's blacklisted")
+	}
+	if _, ok := plugins.GetPlugins(slack.Request{ChannelName: "incidents"}, generalConfig)["xkcd"]; !ok {
+		t.Error("Expected xkcd to be available when only one of its commands is blacklisted")
+	}
+}
+
+func TestForcedEphemeral(t *testing.T) {
+	generalConfig := config.Config{
+		Channels: map[string]config.ChannelPolicy{"general": {Ephemeral: true}},
+	}
+	if !plugins.ForcedEphemeral(slack.Request{ChannelName: "general"}, generalConfig) {
+		t.Error("Expected responses in general to be ephemeral")
+	}
+	if plugins.ForcedEphemeral(slack.Request{ChannelName: "random"}, generalConfig) {
+		t.Error("Expected responses in other channels to follow the plugin")
+	}
+}
+
+func TestActionPolicies(t *testing.T) {
+	err := os.Setenv("IGOR_CONFIG", routerConfig)
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	generalConfig, err := config.GeneralConfig()
+	if err != nil {
+		t.Fatal("Problem getting config")
+	}
+	generalConfig.Channels = map[string]config.ChannelPolicy{
+		"#incidents": {Blacklist: []string{"xkcd.xkcd_random"}},
+	}
+	var actionTests = []struct {
+		channel string
+		action  string
+		allowed bool
+	}{
+		{"incidents", "random", false},
+		{"incidents", "next", true},
+		{"random", "random", true},
+	}
+	for _, tt := range actionTests {
+		request := slack.Request{ChannelName: tt.channel}
+		if _, _, ok := plugins.GetActionPlugin(request, generalConfig, "xkcd", slack.Action{Name: tt.action}); ok != tt.allowed {
+			t.Errorf("%s in #%s: expected allowed %v", tt.action, tt.channel, tt.allowed)
+		}
+	}
+}
@@ -1,45 +1,52 @@
 package plugins
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
 	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
-
 	"github.com/ArjenSchwarz/igor/config"
 	"github.com/ArjenSchwarz/igor/slack"
 )
 
 // RandomTumblrPlugin provides random entries from Tumblr blogs
 type RandomTumblrPlugin struct {
-	name        string
-	description string
-	config      randomTumblrConfig
-	request     slack.Request
+	BasePlugin
+	config   randomTumblrConfig
+	upstream Upstream
 }
 
-// Config returns the plugin configuration
-func (plugin RandomTumblrPlugin) Config() IgorConfig {
-	return plugin.config
+func init() {
+	Register(Registration{
+		Name:     "tumblr",
+		Section:  "randomtumblr",
+		Required: []string{"randomtumblr"},
+		Factory:  RandomTumblr,
+		Actions:  map[string]string{"another": "tumblr"},
+	})
+	// The blogs are configured with their full URL, and every request is
+	// for a different random post so nothing is cached
+	registerUpstream("tumblr", Upstream{Retries: DefaultUpstreamRetries})
 }
 
 // RandomTumblr instantiates a RandomTumblrPlugin
 func RandomTumblr(request slack.Request) (IgorPlugin, error) {
-	pluginName := "randomTumblr"
-	pluginConfig := randomTumblrConfig{
-		languages: getPluginLanguages(pluginName),
+	pluginConfig := randomTumblrConfig{}
+	err := config.ParseTeamConfig(request.TeamID, &pluginConfig)
+	if err != nil {
+		return RandomTumblrPlugin{}, err
 	}
-	err := config.ParseConfig(&pluginConfig)
+	upstreams, err := teamUpstreams(request.TeamID, "tumblr")
 	if err != nil {
 		return RandomTumblrPlugin{}, err
 	}
 	plugin := RandomTumblrPlugin{
-		name:    pluginName,
-		config:  pluginConfig,
-		request: request,
+		BasePlugin: NewBasePlugin("randomTumblr", request),
+		config:     pluginConfig,
+		upstream:   upstreams["tumblr"],
 	}
 	return plugin, nil
 }
@@ -62,42 +69,82 @@ func (plugin RandomTumblrPlugin) Describe(language string) map[string]string {
 //
 // * tumblr
 // * tumblr [configured tumblr name]
-func (plugin RandomTumblrPlugin) Work() (slack.Response, error) {
+func (plugin RandomTumblrPlugin) Work(ctx context.Context) (slack.Response, error) {
 	response := slack.Response{}
-	var chosentumblr tumblrDetails
-	for _, details := range plugin.config.Languages() {
-		maincommand := details.Commands["tumblr"].Command
-		if len(plugin.Message()) == len(maincommand) && plugin.Message() == maincommand {
-			//Not the most efficient way of randomizing, but good enough for a small map
-			rand.Seed(time.Now().UTC().UnixNano())
-			list := []string{}
-			for name := range plugin.config.Randomtumblr {
-				list = append(list, name)
-			}
-			randnr := rand.Intn(len(list))
-			chosentumblr = plugin.config.Randomtumblr[list[randnr]]
-		} else if len(plugin.Message()) > len(maincommand) && plugin.Message()[:len(maincommand)] == maincommand {
-			tumblr := plugin.Message()[len(maincommand)+1:]
-			for name, details := range plugin.config.Randomtumblr {
-				if name == tumblr {
-					chosentumblr = details
-				}
-			}
-		}
-		if chosentumblr.URL != "" {
-			response, err := addTumblrAttachment(response, chosentumblr)
-			if err == nil {
-				response.SetPublic()
-			}
-			return response, err
+	command, err := getCommand(plugin, randomTumblrParams)
+	if err != nil {
+		return response, err
+	}
+	plugin.chosenLanguage = command.Language
+	switch command.Name {
+	case "tumblr":
+		return plugin.tumblrResponse(ctx, response, plugin.randomTumblrName(), false)
+	case "specifictumblr":
+		if _, ok := plugin.config.Randomtumblr[command.Args.String("name")]; ok {
+			return plugin.tumblrResponse(ctx, response, command.Args.String("name"), true)
 		}
 	}
 	return response, CreateNoMatchError("Nothing found")
 }
 
-func addTumblrAttachment(response slack.Response, chosentumblr tumblrDetails) (slack.Response, error) {
+// randomTumblrParams contains the parameters of the commands
+var randomTumblrParams = map[string][]Param{
+	"specifictumblr": {{Name: "name", Type: StringParam}},
+}
+
+// HandleAction handles the button shown below a tumblr post. Handled actions:
+//
+// * another
+func (plugin RandomTumblrPlugin) HandleAction(ctx context.Context, action slack.Action) (slack.Response, error) {
+	response := slack.Response{}
+	if action.Name != "another" {
+		return response, CreateNoMatchError("Unknown action")
+	}
+	chosenname := action.Value
+	if _, ok := plugin.config.Randomtumblr[chosenname]; !ok {
+		chosenname = plugin.randomTumblrName()
+	}
+	if chosenname == "" {
+		return response, CreateNoMatchError("No tumblrs configured")
+	}
+	return plugin.tumblrResponse(ctx, response, chosenname, action.Value != "")
+}
+
+// randomTumblrName returns the name of a random configured tumblr
+func (plugin RandomTumblrPlugin) randomTumblrName() string {
+	//Not the most efficient way of randomizing, but good enough for a small map
+	rand.Seed(time.Now().UTC().UnixNano())
+	list := []string{}
+	for name := range plugin.config.Randomtumblr {
+		list = append(list, name)
+	}
+	if len(list) == 0 {
+		return ""
+	}
+	return list[rand.Intn(len(list))]
+}
+
+// tumblrResponse creates the response for the chosen tumblr. The "another
+// one" button either picks from the same tumblr, or from a random one.
+func (plugin RandomTumblrPlugin) tumblrResponse(ctx context.Context, response slack.Response, chosenname string, specific bool) (slack.Response, error) {
+	response, err := addTumblrAttachment(ctx, plugin.upstream, response, plugin.config.Randomtumblr[chosenname])
+	if err != nil {
+		return response, err
+	}
+	value := ""
+	if specific {
+		value = chosenname
+	}
+	commandDetails := getCommandDetails(plugin, "tumblr")
+	last := len(response.Attachments) - 1
+	response.Attachments[last].AddAction(plugin.name, slack.NewButton("another", commandDetails.Texts["another"], value))
+	response.SetPublic()
+	return response, nil
+}
+
+func addTumblrAttachment(ctx context.Context, upstream Upstream, response slack.Response, chosentumblr tumblrDetails) (slack.Response, error) {
 	url := fmt.Sprintf("%s/random", chosentumblr.URL)
-	doc, err := goquery.NewDocument(url)
+	doc, err := getDocument(ctx, upstream, url)
 	if err != nil {
 		return response, err
 	}
@@ -121,33 +168,8 @@ func addTumblrAttachment(response slack.Response, chosentumblr tumblrDetails) (s
 	return response, err
 }
 
-// Description returns a global description of the plugin
-func (plugin RandomTumblrPlugin) Description(language string) string {
-	return getDescriptionText(plugin, language)
-}
-
-// Name returns the name of the plugin
-func (plugin RandomTumblrPlugin) Name() string {
-	return plugin.name
-}
-
-// Message returns a formatted version of the original message
-func (plugin RandomTumblrPlugin) Message() string {
-	return plugin.request.Text
-}
-
-func (config randomTumblrConfig) Languages() map[string]config.LanguagePluginDetails {
-	return config.languages
-}
-
-func (config randomTumblrConfig) ChosenLanguage() string {
-	return config.chosenLanguage
-}
-
 type randomTumblrConfig struct {
-	Randomtumblr   map[string]tumblrDetails
-	languages      map[string]config.LanguagePluginDetails
-	chosenLanguage string
+	Randomtumblr map[string]tumblrDetails
 }
 
 type tumblrDetails struct {
@@ -0,0 +1,195 @@
+package plugins
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// Limiter keeps track of how often keys are used. Uses are counted in
+// windows of the period, starting at multiples of the period.
+type Limiter interface {
+	// Allow counts a use of the key, and reports whether it's within the
+	// limit of uses in the current window
+	Allow(ctx context.Context, key string, limit int, period time.Duration) (bool, error)
+}
+
+// RateLimitError indicates a user or channel sent more requests to a plugin
+// than its rate limit allows
+type RateLimitError struct {
+	Plugin  string
+	Message string
+}
+
+// Error returns a string interpretation of the RateLimitError
+func (e *RateLimitError) Error() string {
+	return "Rate limit reached for " + e.Plugin
+}
+
+// Response returns the response asking the user to slow down
+func (e *RateLimitError) Response() slack.Response {
+	return slack.Response{Text: e.Message}
+}
+
+// CheckRateLimit counts the request for the plugin, both for the user and
+// for the channel it was sent in. It returns a RateLimitError with the text
+// in the language of the request if either of them has reached the plugin's
+// limit. Without a limiter or a configured limit every request is allowed.
+func CheckRateLimit(ctx context.Context, limiter Limiter, request slack.Request, route Route, config config.Config) error {
+	limit, ok := config.PluginRateLimit(route.Plugin)
+	if limiter == nil || !ok {
+		return nil
+	}
+	checks := []struct {
+		kind  string
+		id    string
+		limit int
+	}{
+		{"user", request.UserID, limit.User},
+		{"channel", request.ChannelID, limit.Channel},
+	}
+	for _, check := range checks {
+		if check.limit <= 0 || check.id == "" {
+			continue
+		}
+		key := strings.Join([]string{check.kind, request.TeamID, check.id, route.Plugin}, ":")
+		allowed, err := limiter.Allow(ctx, key, check.limit, limit.Window())
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return &RateLimitError{
+				Plugin:  route.Plugin,
+				Message: strings.Replace(usageText("slow_down", route.Language, config), "[replace]", route.Plugin, 1),
+			}
+		}
+	}
+	return nil
+}
+
+// RequestLimits charges a single request against the rate limits of the
+// plugins that run for it. Each plugin is charged once, when it's first
+// tried, so a plugin without a limit that can't handle the request doesn't
+// let the plugin it falls back to skip its limit.
+type RequestLimits struct {
+	limiter Limiter
+	request slack.Request
+	config  config.Config
+	charged map[string]bool
+}
+
+// NewRequestLimits creates a RequestLimits for the request
+func NewRequestLimits(limiter Limiter, request slack.Request, config config.Config) *RequestLimits {
+	return &RequestLimits{limiter: limiter, request: request, config: config, charged: map[string]bool{}}
+}
+
+// Check charges the request to the plugin of the route, unless it was
+// already charged to that plugin. See CheckRateLimit for the errors.
+func (limits *RequestLimits) Check(ctx context.Context, route Route) error {
+	if limits.charged[route.Plugin] {
+		return nil
+	}
+	limits.charged[route.Plugin] = true
+	return CheckRateLimit(ctx, limits.limiter, limits.request, route, limits.config)
+}
+
+// MemoryLimiter is a Limiter that keeps its counts in memory. It's meant
+// for running as a server, where every request is handled by the same
+// process.
+type MemoryLimiter struct {
+	lock    sync.Mutex
+	windows map[string]memoryWindow
+}
+
+type memoryWindow struct {
+	start time.Time
+	count int
+}
+
+// NewMemoryLimiter creates a MemoryLimiter
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{windows: make(map[string]memoryWindow)}
+}
+
+// Allow counts a use of the key in the current window
+func (limiter *MemoryLimiter) Allow(ctx context.Context, key string, limit int, period time.Duration) (bool, error) {
+	now := time.Now()
+	start := now.Truncate(period)
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+	// Forget the windows that have passed, so keys don't pile up
+	for name, window := range limiter.windows {
+		if now.Sub(window.start) > 2*period {
+			delete(limiter.windows, name)
+		}
+	}
+	window := limiter.windows[key]
+	if !window.start.Equal(start) {
+		window = memoryWindow{start: start}
+	}
+	if window.count >= limit {
+		return false, nil
+	}
+	window.count++
+	limiter.windows[key] = window
+	return true, nil
+}
+
+// DynamoDBLimiter is a Limiter that keeps its counts in a DynamoDB table,
+// so they're shared between Lambda invocations. The table needs a string
+// partition key called "key", and can use the "expires" attribute as its
+// time to live.
+type DynamoDBLimiter struct {
+	Table string
+}
+
+// NewDynamoDBLimiter creates a DynamoDBLimiter for the table
+func NewDynamoDBLimiter(table string) *DynamoDBLimiter {
+	return &DynamoDBLimiter{Table: table}
+}
+
+// Allow counts a use of the key in the current window. The count is only
+// increased while it's below the limit, so concurrent requests can't go
+// over it.
+func (limiter *DynamoDBLimiter) Allow(ctx context.Context, key string, limit int, period time.Duration) (bool, error) {
+	sess, err := awsSession()
+	if err != nil {
+		return false, err
+	}
+	svc := dynamodb.New(sess)
+	start := time.Now().Truncate(period)
+	params := &dynamodb.UpdateItemInput{
+		TableName: aws.String(limiter.Table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"key": {S: aws.String(key + ":" + strconv.FormatInt(start.Unix(), 10))},
+		},
+		UpdateExpression:    aws.String("ADD #count :one SET #expires = :expires"),
+		ConditionExpression: aws.String("attribute_not_exists(#count) OR #count < :limit"),
+		ExpressionAttributeNames: map[string]*string{
+			"#count":   aws.String("count"),
+			"#expires": aws.String("expires"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one":     {N: aws.String("1")},
+			":limit":   {N: aws.String(strconv.Itoa(limit))},
+			":expires": {N: aws.String(strconv.FormatInt(start.Add(2*period).Unix(), 10))},
+		},
+	}
+	_, err = svc.UpdateItemWithContext(ctx, params)
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
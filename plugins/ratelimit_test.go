@@ -0,0 +1,114 @@
+package plugins_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/plugins"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	limiter := plugins.NewMemoryLimiter()
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.Allow(context.Background(), "user", 2, time.Hour); !allowed {
+			t.Errorf("Expected use %d to be allowed", i+1)
+		}
+	}
+	if allowed, _ := limiter.Allow(context.Background(), "user", 2, time.Hour); allowed {
+		t.Error("Expected the third use to go over the limit")
+	}
+	if allowed, _ := limiter.Allow(context.Background(), "other", 2, time.Hour); !allowed {
+		t.Error("Expected other keys to have their own count")
+	}
+}
+
+// failingLimiter is a Limiter that can't check the limits
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(ctx context.Context, key string, limit int, period time.Duration) (bool, error) {
+	return false, errors.New("table not found")
+}
+
+func TestCheckRateLimit(t *testing.T) {
+	err := os.Setenv("IGOR_CONFIG", "{\"token\": \"testtoken\", \"languagedir\": \"../language\"}")
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	generalConfig, err := config.GeneralConfig()
+	if err != nil {
+		t.Fatal("Problem getting config")
+	}
+	generalConfig.RateLimits = map[string]config.RateLimit{
+		"xkcd":    {User: 1, Channel: 3, Period: "1h"},
+		"default": {Channel: 10},
+	}
+	limiter := plugins.NewMemoryLimiter()
+	route := plugins.Route{Plugin: "xkcd", Language: "nederlands.yml"}
+	first := slack.Request{TeamID: "T1", UserID: "U1", ChannelID: "C1"}
+	if err := plugins.CheckRateLimit(context.Background(), limiter, first, route, generalConfig); err != nil {
+		t.Errorf("Expected the first request to be allowed, actual %v", err)
+	}
+	err = plugins.CheckRateLimit(context.Background(), limiter, first, route, generalConfig)
+	limitErr, ok := err.(*plugins.RateLimitError)
+	if !ok {
+		t.Fatalf("Expected a RateLimitError for the user's second request, actual %v", err)
+	}
+	if !strings.Contains(limitErr.Response().Text, "Rustig aan") {
+		t.Errorf("Expected the response in the language of the command, actual %q", limitErr.Response().Text)
+	}
+	other := slack.Request{TeamID: "T1", UserID: "U2", ChannelID: "C1"}
+	if err := plugins.CheckRateLimit(context.Background(), limiter, other, route, generalConfig); err != nil {
+		t.Errorf("Expected other users to have their own limit, actual %v", err)
+	}
+	if err := plugins.CheckRateLimit(context.Background(), limiter, first, plugins.Route{Plugin: "status"}, generalConfig); err != nil {
+		t.Errorf("Expected the default limit for other plugins, actual %v", err)
+	}
+	if err := plugins.CheckRateLimit(context.Background(), nil, first, route, generalConfig); err != nil {
+		t.Errorf("Expected no limits without a limiter, actual %v", err)
+	}
+	if err := plugins.CheckRateLimit(context.Background(), failingLimiter{}, first, route, generalConfig); err == nil || err.Error() != "table not found" {
+		t.Errorf("Expected the limiter's error, actual %v", err)
+	}
+}
+
+func TestRequestLimits(t *testing.T) {
+	err := os.Setenv("IGOR_CONFIG", "{\"token\": \"testtoken\"}")
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	generalConfig, err := config.GeneralConfig()
+	if err != nil {
+		t.Fatal("Problem getting config")
+	}
+	generalConfig.RateLimits = map[string]config.RateLimit{
+		"xkcd": {User: 1, Period: "1h"},
+	}
+	limiter := plugins.NewMemoryLimiter()
+	request := slack.Request{TeamID: "T1", UserID: "U1", ChannelID: "C1"}
+	// An unlimited plugin declines the first request and the limited
+	// plugin answers it
+	limits := plugins.NewRequestLimits(limiter, request, generalConfig)
+	if err := limits.Check(context.Background(), plugins.Route{Plugin: "status"}); err != nil {
+		t.Errorf("Expected no limit for the unlimited plugin, actual %v", err)
+	}
+	if err := limits.Check(context.Background(), plugins.Route{Plugin: "xkcd"}); err != nil {
+		t.Errorf("Expected the first request to be allowed, actual %v", err)
+	}
+	if err := limits.Check(context.Background(), plugins.Route{Plugin: "xkcd", Command: "xkcd"}); err != nil {
+		t.Errorf("Expected a plugin to be charged once per request, actual %v", err)
+	}
+	// The same fallback for the next request reaches the limit
+	limits = plugins.NewRequestLimits(limiter, request, generalConfig)
+	if err := limits.Check(context.Background(), plugins.Route{Plugin: "status"}); err != nil {
+		t.Errorf("Expected no limit for the unlimited plugin, actual %v", err)
+	}
+	if _, ok := limits.Check(context.Background(), plugins.Route{Plugin: "xkcd"}).(*plugins.RateLimitError); !ok {
+		t.Error("Expected the limit of the plugin that was fallen back to")
+	}
+}
@@ -0,0 +1,281 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// Factory creates an instance of a plugin for a request
+type Factory func(request slack.Request) (IgorPlugin, error)
+
+// Registration describes a plugin and how to create it. Plugins register
+// themselves from an init function in the file that implements them.
+type Registration struct {
+	// Name is the name used for the plugin in the configuration, like in
+	// the whitelist, blacklist, and async settings
+	Name string
+	// Section is the section of the configuration file that contains the
+	// plugin's settings, if it has any
+	Section string
+	// Required are the settings the plugin can't work without, with a dot
+	// between a section and its settings like "weather.apitoken". The
+	// plugin is disabled when any of them are missing.
+	Required []string
+	// Factory creates the plugin for a request
+	Factory Factory
+	// Permissions are the permissions needed for commands of the plugin,
+	// by the name of the command. Roles grant these permissions, and the
+	// configuration can change them.
+	Permissions map[string]string
+	// Actions are the commands the plugin's buttons belong to, by the name
+	// of the action. The channel policies of the command apply to the
+	// action as well.
+	Actions map[string]string
+	// Roles provides the roles defined in the plugin's own settings for
+	// the team, like the admins of the remember plugin
+	Roles func(teamID string) (map[string]config.Role, error)
+}
+
+// Diagnostic explains why a plugin is disabled
+type Diagnostic struct {
+	Plugin string
+	Err    error
+}
+
+// String describes the plugin and the reason it's disabled
+func (diagnostic Diagnostic) String() string {
+	return fmt.Sprintf("Plugin %s is disabled: %s", diagnostic.Plugin, diagnostic.Err)
+}
+
+// Source provides the registrations of plugins that are defined in the
+// configuration instead of in code
+type Source func() ([]Registration, error)
+
+var (
+	registry = map[string]Registration{}
+	sources  = map[string]Source{}
+)
+
+// loadedRegistry contains the registrations built for a configuration, and
+// the settings of every team that's checked for required settings. These
+// are built once and reused for every request, until the configuration
+// changes.
+type loadedRegistry struct {
+	contents      string
+	registrations []Registration
+	diagnostics   []Diagnostic
+	teamSettings  map[string]map[string]interface{}
+}
+
+var (
+	loaded     *loadedRegistry
+	loadedLock sync.Mutex
+)
+
+// Register adds a plugin to the registry. It panics if the registration is
+// incomplete or the name is already in use, as that's a programming error.
+func Register(registration Registration) {
+	if registration.Name == "" || registration.Factory == nil {
+		panic("plugins: a registration needs a name and a factory")
+	}
+	if _, ok := registry[registration.Name]; ok {
+		panic("plugins: Register called twice for plugin " + registration.Name)
+	}
+	registry[registration.Name] = registration
+}
+
+// Registered returns the registrations of all plugins in code, sorted by
+// name
+func Registered() []Registration {
+	registrations := []Registration{}
+	for _, registration := range registry {
+		registrations = append(registrations, registration)
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].Name < registrations[j].Name
+	})
+	return registrations
+}
+
+// registerSource adds a source of plugins defined in the configuration
+func registerSource(name string, source Source) {
+	sources[name] = source
+}
+
+// registrations returns the registrations of the plugins in code and those
+// from the sources, sorted by name. Problems with the sources are returned
+// as diagnostics.
+func registrations() ([]Registration, []Diagnostic) {
+	current := loadRegistry()
+	return current.registrations, append([]Diagnostic{}, current.diagnostics...)
+}
+
+// loadRegistry returns the registrations built for the current
+// configuration, and builds them when the configuration changed
+func loadRegistry() *loadedRegistry {
+	contents, _ := config.Contents()
+	loadedLock.Lock()
+	defer loadedLock.Unlock()
+	if loaded == nil || loaded.contents != string(contents) {
+		all, diagnostics := buildRegistrations()
+		loaded = &loadedRegistry{
+			contents:      string(contents),
+			registrations: all,
+			diagnostics:   diagnostics,
+			teamSettings:  make(map[string]map[string]interface{}),
+		}
+	}
+	return loaded
+}
+
+// buildRegistrations combines the plugins in code with those from the
+// sources, including plugins that use a name that's already taken as a
+// diagnostic
+func buildRegistrations() ([]Registration, []Diagnostic) {
+	all := Registered()
+	diagnostics := []Diagnostic{}
+	taken := make(map[string]bool)
+	for _, registration := range all {
+		taken[registration.Name] = true
+	}
+	names := []string{}
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		configured, err := sources[name]()
+		if err != nil {
+			diagnostics = append(diagnostics, Diagnostic{Plugin: name, Err: err})
+			continue
+		}
+		for _, registration := range configured {
+			if taken[registration.Name] {
+				diagnostics = append(diagnostics, Diagnostic{
+					Plugin: registration.Name,
+					Err:    errors.New("the name is already used by another plugin"),
+				})
+				continue
+			}
+			taken[registration.Name] = true
+			all = append(all, registration)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	return all, diagnostics
+}
+
+// create instantiates the plugin, after checking its required settings are
+// present
+func (registration Registration) create(request slack.Request) (IgorPlugin, error) {
+	if len(registration.Required) != 0 {
+		missing, err := loadRegistry().missingSettings(request.TeamID, registration.Required)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) != 0 {
+			return nil, fmt.Errorf("missing required settings %s", strings.Join(missing, ", "))
+		}
+	}
+	return registration.Factory(request)
+}
+
+// activated checks if the plugin is allowed by the whitelist and blacklist.
+// Help is always activated, as it's required.
+func activated(name string, config config.Config) bool {
+	if name == "help" {
+		return true
+	}
+	if config.Whitelist != nil && !contains(config.Whitelist, name) {
+		return false
+	}
+	return !contains(config.Blacklist, name)
+}
+
+// Diagnostics creates every activated plugin, and reports the ones that
+// can't be created. These plugins are disabled, as their configuration or
+// environment isn't valid.
+func Diagnostics(config config.Config) []Diagnostic {
+	all, diagnostics := registrations()
+	for _, registration := range all {
+		if !activated(registration.Name, config) {
+			continue
+		}
+		if _, err := registration.create(slack.Request{}); err != nil {
+			diagnostics = append(diagnostics, Diagnostic{Plugin: registration.Name, Err: err})
+		}
+	}
+	return diagnostics
+}
+
+// missingSettings returns the required settings that aren't set in the
+// configuration file, for the team or globally. The team's settings are
+// parsed the first time they're needed. Names are compared case
+// insensitively, like when the configuration is parsed.
+func (current *loadedRegistry) missingSettings(teamID string, required []string) ([]string, error) {
+	loadedLock.Lock()
+	values, ok := current.teamSettings[teamID]
+	loadedLock.Unlock()
+	if !ok {
+		values = make(map[string]interface{})
+		if err := config.ParseTeamConfig(teamID, &values); err != nil {
+			return nil, err
+		}
+		loadedLock.Lock()
+		current.teamSettings[teamID] = values
+		loadedLock.Unlock()
+	}
+	missing := []string{}
+	for _, name := range required {
+		var value interface{} = values
+		for _, key := range strings.Split(name, ".") {
+			value = setting(value, key)
+		}
+		switch value := value.(type) {
+		case nil:
+			missing = append(missing, name)
+		case string:
+			if value == "" {
+				missing = append(missing, name)
+			}
+		}
+	}
+	return missing, nil
+}
+
+// setting retrieves a value from a parsed section of the configuration
+func setting(values interface{}, name string) interface{} {
+	switch section := values.(type) {
+	case map[string]interface{}:
+		for key, value := range section {
+			if strings.EqualFold(key, name) {
+				return value
+			}
+		}
+	case map[interface{}]interface{}:
+		for key, value := range section {
+			if strings.EqualFold(fmt.Sprint(key), name) {
+				return value
+			}
+		}
+	}
+	return nil
+}
+
+// contains checks if the list contains the value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
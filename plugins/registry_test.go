@@ -0,0 +1,87 @@
+package plugins_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/plugins"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestRegistered(t *testing.T) {
+	registered := make(map[string]bool)
+	for _, registration := range plugins.Registered() {
+		registered[registration.Name] = true
+	}
+	for _, name := range []string{"help", "weather", "tumblr", "status", "xkcd", "remember"} {
+		if !registered[name] {
+			t.Errorf("Expected plugin %s to be registered", name)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	err := os.Setenv("IGOR_CONFIG", "{\"token\": \"testtoken\", \"languagedir\": \"../language\", \"weather\": {\"apitoken\": \"\"}}")
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	generalConfig, err := config.GeneralConfig()
+	if err != nil {
+		t.Fatal("Problem getting config")
+	}
+	disabled := make(map[string]string)
+	for _, diagnostic := range plugins.Diagnostics(generalConfig) {
+		disabled[diagnostic.Plugin] = diagnostic.String()
+	}
+	var diagnosticTests = []struct {
+		plugin  string
+		setting string
+	}{
+		{"weather", "weather.apitoken"},
+		{"remember", "remember.dynamodb"},
+		{"tumblr", "randomtumblr"},
+	}
+	for _, tt := range diagnosticTests {
+		if !strings.Contains(disabled[tt.plugin], tt.setting) {
+			t.Errorf("Expected %s to be disabled for missing %s, actual %q", tt.plugin, tt.setting, disabled[tt.plugin])
+		}
+	}
+	if _, ok := disabled["help"]; ok {
+		t.Error("Expected help to be enabled")
+	}
+	activated := plugins.GetPlugins(slack.Request{}, generalConfig)
+	if _, ok := activated["weather"]; ok {
+		t.Error("Expected disabled plugins to be left out")
+	}
+
+	generalConfig.Whitelist = []string{"xkcd"}
+	generalConfig.Blacklist = []string{"xkcd", "help"}
+	diagnostics := plugins.Diagnostics(generalConfig)
+	if len(diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics for plugins that aren't activated, actual %v", diagnostics)
+	}
+	activated = plugins.GetPlugins(slack.Request{}, generalConfig)
+	if _, ok := activated["help"]; !ok || len(activated) != 1 {
+		t.Errorf("Expected only help to be activated, actual %v", activated)
+	}
+}
+
+func TestTeamSettings(t *testing.T) {
+	err := os.Setenv("IGOR_CONFIG", `{"token": "testtoken", "languagedir": "../language",
+		"teams": {"T0001": {"remember": {"dynamodb": "teamRemember"}}}}`)
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	generalConfig, err := config.GeneralConfig()
+	if err != nil {
+		t.Fatal("Problem getting config")
+	}
+	if _, ok := plugins.GetPlugins(slack.Request{TeamID: "T0002"}, generalConfig)["remember"]; ok {
+		t.Error("Expected remember to be disabled for a team without a table")
+	}
+	if _, ok := plugins.GetPlugins(slack.Request{TeamID: "T0001"}, generalConfig)["remember"]; !ok {
+		t.Error("Expected remember to be enabled for the team with a table")
+	}
+}
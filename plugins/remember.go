@@ -1,12 +1,12 @@
 package plugins
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 
 	"github.com/ArjenSchwarz/igor/config"
@@ -15,24 +15,36 @@ import (
 
 // RememberPlugin provides remember functions
 type RememberPlugin struct {
-	name        string
-	description string
-	request     slack.Request
-	config      rememberConfig
+	BasePlugin
+	config rememberConfig
+}
+
+func init() {
+	Register(Registration{
+		Name:     "remember",
+		Section:  "remember",
+		Required: []string{"remember.dynamodb"},
+		Factory:  Remember,
+		Permissions: map[string]string{
+			"forget": "remember.forget",
+		},
+		Actions: map[string]string{
+			"forget": "forget",
+			"cancel": "forget",
+		},
+		Roles: rememberRoles,
+	})
 }
 
 // Remember instantiates the RememberPlugin
 func Remember(request slack.Request) (IgorPlugin, error) {
-	pluginName := "remember"
-	pluginConfig, err := parseRememberConfig()
+	pluginConfig, err := parseRememberConfig(request.TeamID)
 	if err != nil {
 		return RememberPlugin{}, err
 	}
-	pluginConfig.languages = getPluginLanguages(pluginName)
 	plugin := RememberPlugin{
-		name:    pluginName,
-		request: request,
-		config:  pluginConfig,
+		BasePlugin: NewBasePlugin("remember", request),
+		config:     pluginConfig,
 	}
 
 	return plugin, nil
@@ -43,26 +55,26 @@ func Remember(request slack.Request) (IgorPlugin, error) {
 //
 //  * remember
 //  * remember2
-func (plugin RememberPlugin) Work() (slack.Response, error) {
+func (plugin RememberPlugin) Work(ctx context.Context) (slack.Response, error) {
 	response := slack.Response{}
-	if plugin.config.Dynamodb == "" {
-		return response, CreateNoMatchError("No DynamoDB configured")
+	command, err := getCommand(plugin, rememberParams)
+	if err != nil {
+		return response, err
 	}
-	message, language := getCommandName(plugin)
-	plugin.config.chosenLanguage = language
-	switch message {
+	plugin.chosenLanguage = command.Language
+	switch command.Name {
 	case "remember":
-		tmpresponse, err := plugin.handleRemember(response)
+		tmpresponse, err := plugin.handleRemember(ctx, response, command.Args)
 		if err != nil {
 			return tmpresponse, err
 		}
 		response = tmpresponse
 	case "show":
-		return plugin.handleShow(response)
+		return plugin.handleShow(ctx, response, command.Args)
 	case "forget":
-		return plugin.handleForget(response)
+		return plugin.handleForget(ctx, response, command.Args)
 	case "showall":
-		return plugin.handleShowAll(response)
+		return plugin.handleShowAll(ctx, response)
 	}
 	if response.Text == "" {
 		return response, CreateNoMatchError("Nothing found")
@@ -70,32 +82,24 @@ func (plugin RememberPlugin) Work() (slack.Response, error) {
 	return response, nil
 }
 
-// Describe provides the triggers RememberPlugin can handle
-func (plugin RememberPlugin) Describe(language string) map[string]string {
-	descriptions := make(map[string]string)
-	if plugin.config.Dynamodb == "" {
-		return descriptions
-	}
-	for commandName, values := range getAllCommands(plugin, language) {
-		if commandName != "forget" || plugin.request.UserInList(plugin.config.Admins) {
-			descriptions[values.Command] = values.Description
-		}
-	}
-	return descriptions
+// rememberParams contains the parameters of the commands
+var rememberParams = map[string][]Param{
+	"remember": {{Name: "name", Type: StringParam}, {Name: "url", Type: URLParam}},
+	"show":     {{Name: "name", Type: StringParam}},
+	"forget":   {{Name: "name", Type: StringParam}},
 }
 
-func (plugin RememberPlugin) handleRemember(response slack.Response) (slack.Response, error) {
+func (plugin RememberPlugin) handleRemember(ctx context.Context, response slack.Response, args Args) (slack.Response, error) {
 	commandDetails := getCommandDetails(plugin, "remember")
 	if plugin.request.UserInList(plugin.config.Blacklist) {
 		response.Text = commandDetails.Texts["forbidden"]
 		return response, nil
 	}
-	parts := strings.Split(plugin.Message(), " ")
-	name := strings.TrimSpace(parts[1])
-	url := strings.TrimSpace(parts[2])
+	name := args.String("name")
+	url := args.String("url")
 
 	response.Text = strings.Replace(commandDetails.Texts["response_text"], "[replace]", name, 1)
-	sess, err := session.NewSession()
+	sess, err := awsSession()
 	if err != nil {
 		return response, err
 	}
@@ -110,24 +114,70 @@ func (plugin RememberPlugin) handleRemember(response slack.Response) (slack.Resp
 		},
 		TableName: aws.String(plugin.config.Dynamodb),
 	}
-	_, err = svc.PutItem(params)
+	_, err = svc.PutItemWithContext(ctx, params)
 
 	return response, err
 }
 
-func (plugin RememberPlugin) handleForget(response slack.Response) (slack.Response, error) {
+// handleForget asks for confirmation before forgetting an image, the actual
+// removal is done through HandleAction
+func (plugin RememberPlugin) handleForget(ctx context.Context, response slack.Response, args Args) (slack.Response, error) {
 	commandDetails := getCommandDetails(plugin, "forget")
-	if !plugin.request.UserInList(plugin.config.Admins) {
+	if !plugin.canForget(ctx) {
 		response.Text = commandDetails.Texts["forbidden"]
 		return response, nil
 	}
-	parts := strings.Split(plugin.Message(), " ")
-	name := strings.TrimSpace(parts[1])
+	name := args.String("name")
+
+	response.Text = strings.Replace(commandDetails.Texts["confirm_text"], "[replace]", name, 1)
+	attach := slack.Attachment{}
+	confirm := slack.NewButton("forget", commandDetails.Texts["confirm_button"], name)
+	confirm.Style = slack.ActionDanger
+	attach.AddAction(plugin.name, confirm)
+	attach.AddAction(plugin.name, slack.NewButton("cancel", commandDetails.Texts["cancel_button"], name))
+	response.AddAttachment(attach)
+	return response, nil
+}
 
-	response.Text = strings.Replace(commandDetails.Texts["response_text"], "[replace]", name, 1)
-	sess, err := session.NewSession()
+// HandleAction handles the confirmation buttons for forgetting an image.
+// Handled actions:
+//
+// * forget
+// * cancel
+func (plugin RememberPlugin) HandleAction(ctx context.Context, action slack.Action) (slack.Response, error) {
+	response := slack.Response{ReplaceOriginal: true}
+	commandDetails := getCommandDetails(plugin, "forget")
+	switch action.Name {
+	case "forget":
+		if !plugin.canForget(ctx) {
+			return response, &PermissionError{
+				Plugin:     "remember",
+				Command:    "forget",
+				Permission: "remember.forget",
+				Message:    commandDetails.Texts["forbidden"],
+			}
+		}
+		response.Text = strings.Replace(commandDetails.Texts["response_text"], "[replace]", action.Value, 1)
+		return response, plugin.forget(ctx, action.Value)
+	case "cancel":
+		response.Text = strings.Replace(commandDetails.Texts["cancelled"], "[replace]", action.Value, 1)
+		return response, nil
+	}
+	return response, CreateNoMatchError("Unknown action")
+}
+
+// canForget checks if the user has the permission to make Igor forget
+// images. The button for confirming it is handled outside of the checks done
+// for commands, so it's checked here as well.
+func (plugin RememberPlugin) canForget(ctx context.Context) bool {
+	return hasPermission(ctx, plugin.request, plugin.name, "remember.forget", teamConfig(plugin))
+}
+
+// forget removes the image with the provided name
+func (plugin RememberPlugin) forget(ctx context.Context, name string) error {
+	sess, err := awsSession()
 	if err != nil {
-		return response, err
+		return err
 	}
 
 	svc := dynamodb.New(sess)
@@ -138,19 +188,16 @@ func (plugin RememberPlugin) handleForget(response slack.Response) (slack.Respon
 		},
 		TableName: aws.String(plugin.config.Dynamodb), // Required
 	}
-	_, err = svc.DeleteItem(params)
-	return response, err
+	_, err = svc.DeleteItemWithContext(ctx, params)
+	return err
 }
 
-func (plugin RememberPlugin) handleShow(response slack.Response) (slack.Response, error) {
-	var subject string
+func (plugin RememberPlugin) handleShow(ctx context.Context, response slack.Response, args Args) (slack.Response, error) {
+	subject := args.String("name")
 	commandDetails := getCommandDetails(plugin, "show")
-	parts := strings.Split(plugin.Message(), " ")
-	subject = strings.TrimSpace(strings.Replace(plugin.Message(), parts[0], "", 1))
 
-	sess, err := session.NewSession()
+	sess, err := awsSession()
 	if err != nil {
-		fmt.Println("failed to create session,", err)
 		return response, err
 	}
 
@@ -162,7 +209,7 @@ func (plugin RememberPlugin) handleShow(response slack.Response) (slack.Response
 		},
 		TableName: aws.String(plugin.config.Dynamodb),
 	}
-	resp, err := svc.GetItem(params)
+	resp, err := svc.GetItemWithContext(ctx, params)
 
 	if err != nil {
 		if awsErr, ok := err.(awserr.Error); ok {
@@ -183,11 +230,10 @@ func (plugin RememberPlugin) handleShow(response slack.Response) (slack.Response
 	return response, nil
 }
 
-func (plugin RememberPlugin) handleShowAll(response slack.Response) (slack.Response, error) {
+func (plugin RememberPlugin) handleShowAll(ctx context.Context, response slack.Response) (slack.Response, error) {
 	commandDetails := getCommandDetails(plugin, "showall")
-	sess, err := session.NewSession()
+	sess, err := awsSession()
 	if err != nil {
-		fmt.Println("failed to create session,", err)
 		return response, err
 	}
 
@@ -196,7 +242,7 @@ func (plugin RememberPlugin) handleShowAll(response slack.Response) (slack.Respo
 	params := &dynamodb.ScanInput{
 		TableName: aws.String(plugin.config.Dynamodb),
 	}
-	resp, err := svc.Scan(params)
+	resp, err := svc.ScanWithContext(ctx, params)
 	if err != nil {
 		return response, err
 	}
@@ -216,25 +262,18 @@ func (plugin RememberPlugin) handleShowAll(response slack.Response) (slack.Respo
 
 // Functions to satisfy the interfaces are below
 
-// Config returns the plugin configuration
-func (plugin RememberPlugin) Config() IgorConfig {
-	return plugin.config
-}
-
 type rememberConfig struct {
-	languages      map[string]config.LanguagePluginDetails
-	chosenLanguage string
-	Dynamodb       string
-	Admins         []string
-	Blacklist      []string
+	Dynamodb  string
+	Admins    []string
+	Blacklist []string
 }
 
-func parseRememberConfig() (rememberConfig, error) {
+func parseRememberConfig(teamID string) (rememberConfig, error) {
 	pluginConfig := struct {
 		Remember rememberConfig
 	}{}
 
-	err := config.ParseConfig(&pluginConfig)
+	err := config.ParseTeamConfig(teamID, &pluginConfig)
 	if err != nil {
 		return pluginConfig.Remember, err
 	}
@@ -242,31 +281,18 @@ func parseRememberConfig() (rememberConfig, error) {
 	return pluginConfig.Remember, nil
 }
 
-type rememberDetails struct {
-	Dynamodb string
-}
-
-// Languages returns the languages available for the plugin
-func (config rememberConfig) Languages() map[string]config.LanguagePluginDetails {
-	return config.languages
-}
-
-// ChosenLanguage returns the language active for this plugin
-func (config rememberConfig) ChosenLanguage() string {
-	return config.chosenLanguage
-}
-
-// Description returns a global description of the plugin
-func (plugin RememberPlugin) Description(language string) string {
-	return getDescriptionText(plugin, language)
-}
-
-// Name returns the name of the plugin
-func (plugin RememberPlugin) Name() string {
-	return plugin.name
+// rememberRoles provides the admins from the remember settings as a role
+// with the permission to make Igor forget images
+func rememberRoles(teamID string) (map[string]config.Role, error) {
+	pluginConfig, err := parseRememberConfig(teamID)
+	if err != nil || len(pluginConfig.Admins) == 0 {
+		return nil, err
+	}
+	return map[string]config.Role{
+		"remember.admins": {Members: pluginConfig.Admins, Permissions: []string{"remember.forget"}},
+	}, nil
 }
 
-// Message returns a formatted version of the original message
-func (plugin RememberPlugin) Message() string {
-	return plugin.request.Text
+type rememberDetails struct {
+	Dynamodb string
 }
@@ -0,0 +1,267 @@
+package plugins
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// Route is a command of a plugin in a specific language, with its template
+// compiled into a pattern. Words in square brackets in the template, like
+// [city], are parameters. Parameters at the end of a template are optional,
+// and the last one takes the remainder of the message.
+type Route struct {
+	Plugin   string
+	Command  string
+	Language string
+	Template string
+	Params   []string
+	literals int
+	pattern  *regexp.Regexp
+}
+
+// Match is an activated plugin with the route that matched the message, and
+// the values for the route's parameters. Parameters that weren't provided
+// have an empty value.
+type Match struct {
+	Plugin IgorPlugin
+	Route  Route
+	Args   []string
+}
+
+// Ambiguity is a template that's used by multiple plugins, without a
+// configured priority to decide between them
+type Ambiguity struct {
+	Template string
+	Routes   []Route
+}
+
+// String describes the ambiguity and which route is used
+func (ambiguity Ambiguity) String() string {
+	claims := []string{}
+	for _, route := range ambiguity.Routes {
+		claims = append(claims, fmt.Sprintf("%s (%s, %s)", route.Plugin, route.Command, route.Language))
+	}
+	return fmt.Sprintf("%s is used by %s; %s is used",
+		ambiguity.Template,
+		strings.Join(claims, " and "),
+		ambiguity.Routes[0].Plugin)
+}
+
+// compiledTemplate is the result of compiling a template
+type compiledTemplate struct {
+	pattern  *regexp.Regexp
+	params   []string
+	literals int
+}
+
+var (
+	templateCache = make(map[string]compiledTemplate)
+	templateLock  sync.Mutex
+	paramRegex    = regexp.MustCompile(`^\[([^\]]*)\]$`)
+)
+
+// compileTemplate turns a template into a case insensitive pattern. Compiled
+// templates are cached, as they're used for every request.
+func compileTemplate(template string) compiledTemplate {
+	templateLock.Lock()
+	defer templateLock.Unlock()
+	if compiled, ok := templateCache[template]; ok {
+		return compiled
+	}
+	words := strings.Fields(template)
+	// Parameters after the last literal word are optional, but the first
+	// word is always required
+	trailing := len(words)
+	for trailing > 1 && paramRegex.MatchString(words[trailing-1]) {
+		trailing--
+	}
+	compiled := compiledTemplate{}
+	parts := []string{}
+	for _, word := range words[:trailing] {
+		if param := paramRegex.FindStringSubmatch(word); param != nil {
+			compiled.params = append(compiled.params, param[1])
+			parts = append(parts, `(.+?)`)
+		} else {
+			compiled.literals++
+			parts = append(parts, regexp.QuoteMeta(word))
+		}
+	}
+	optional := ""
+	for i := len(words) - 1; i >= trailing; i-- {
+		value := `(\S+)`
+		if i == len(words)-1 {
+			value = `(.+)`
+		}
+		optional = `(?:\s+` + value + optional + `)?`
+	}
+	for _, word := range words[trailing:] {
+		compiled.params = append(compiled.params, paramRegex.FindStringSubmatch(word)[1])
+	}
+	pattern := strings.Join(parts, `\s+`) + optional
+	compiled.pattern = regexp.MustCompile(`(?is)^` + pattern + `$`)
+	templateCache[template] = compiled
+	return compiled
+}
+
+// pluginRoutes compiles the commands of all the plugin's languages
+func pluginRoutes(name string, plugin IgorPlugin) []Route {
+	routes := []Route{}
+	for language, details := range plugin.Config().Languages() {
+		for command, value := range details.Commands {
+			if strings.TrimSpace(value.Command) == "" {
+				continue
+			}
+			compiled := compileTemplate(value.Command)
+			routes = append(routes, Route{
+				Plugin:   name,
+				Command:  command,
+				Language: language,
+				Template: value.Command,
+				Params:   compiled.params,
+				literals: compiled.literals,
+				pattern:  compiled.pattern,
+			})
+		}
+	}
+	return routes
+}
+
+// matchRoutes returns the matches for the message, with the best match first.
+// Commands that aren't allowed in the channel of the plugin's request are
+// skipped.
+func matchRoutes(plugins map[string]IgorPlugin, message string, config config.Config) []Match {
+	message = strings.TrimSpace(message)
+	matches := []Match{}
+	for name, plugin := range plugins {
+		if plugin == nil {
+			continue
+		}
+		request := pluginRequest(plugin)
+		for _, route := range pluginRoutes(name, plugin) {
+			values := route.pattern.FindStringSubmatch(message)
+			if values == nil || !commandAllowed(route, request, config) {
+				continue
+			}
+			matches = append(matches, Match{Plugin: plugin, Route: route, Args: values[1:]})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return routeLess(matches[i].Route, matches[j].Route, config)
+	})
+	return matches
+}
+
+// routeLess determines whether route a is preferred over route b. Plugins
+// with a configured priority come first, then the most specific route,
+// which is the one with the most literal words and the fewest parameters.
+// Anything else is decided by name, with the default language first, so the
+// outcome is always the same.
+func routeLess(a, b Route, config config.Config) bool {
+	if pa, pb := pluginPriority(a.Plugin, config), pluginPriority(b.Plugin, config); pa != pb {
+		return pa < pb
+	}
+	if a.literals != b.literals {
+		return a.literals > b.literals
+	}
+	if len(a.Params) != len(b.Params) {
+		return len(a.Params) < len(b.Params)
+	}
+	if a.Plugin != b.Plugin {
+		return a.Plugin < b.Plugin
+	}
+	if a.Command != b.Command {
+		return a.Command < b.Command
+	}
+	if (a.Language == config.DefaultLanguage) != (b.Language == config.DefaultLanguage) {
+		return a.Language == config.DefaultLanguage
+	}
+	return a.Language < b.Language
+}
+
+// pluginPriority returns the position of the plugin in the configured
+// priority. Plugins that aren't listed share the lowest priority.
+func pluginPriority(name string, config config.Config) int {
+	for i, plugin := range config.Priority {
+		if plugin == name {
+			return i
+		}
+	}
+	return len(config.Priority)
+}
+
+// MatchingPlugins returns the activated plugins with a command matching the
+// request, with the best match first. Every plugin is included once, with
+// its best matching route.
+func MatchingPlugins(request slack.Request, config config.Config) []Match {
+	matches := []Match{}
+	seen := make(map[string]bool)
+	for _, match := range matchRoutes(GetPlugins(request, config), request.Text, config) {
+		if !seen[match.Route.Plugin] {
+			seen[match.Route.Plugin] = true
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// Ambiguities finds the templates that are used by multiple activated
+// plugins with the same priority. These are still handled the same way
+// every time, but it's unlikely to be what was intended. Overlapping
+// commands within a single plugin are left to the plugin.
+func Ambiguities(config config.Config) []Ambiguity {
+	return findAmbiguities(GetPlugins(slack.Request{}, config), config)
+}
+
+// findAmbiguities finds the templates used by multiple of the plugins
+func findAmbiguities(plugins map[string]IgorPlugin, config config.Config) []Ambiguity {
+	shapes := make(map[string][]Route)
+	for name, plugin := range plugins {
+		if plugin == nil {
+			continue
+		}
+		for _, route := range pluginRoutes(name, plugin) {
+			shape := templateShape(route.Template)
+			shapes[shape] = append(shapes[shape], route)
+		}
+	}
+	ambiguities := []Ambiguity{}
+	for shape, routes := range shapes {
+		sort.SliceStable(routes, func(i, j int) bool {
+			return routeLess(routes[i], routes[j], config)
+		})
+		contenders := []Route{routes[0]}
+		for _, route := range routes[1:] {
+			if pluginPriority(route.Plugin, config) != pluginPriority(routes[0].Plugin, config) {
+				break
+			}
+			if route.Plugin != contenders[len(contenders)-1].Plugin {
+				contenders = append(contenders, route)
+			}
+		}
+		if len(contenders) > 1 {
+			ambiguities = append(ambiguities, Ambiguity{Template: shape, Routes: contenders})
+		}
+	}
+	sort.Slice(ambiguities, func(i, j int) bool {
+		return ambiguities[i].Template < ambiguities[j].Template
+	})
+	return ambiguities
+}
+
+// templateShape normalises a template so templates that match the same
+// messages are equal
+func templateShape(template string) string {
+	words := strings.Fields(strings.ToLower(template))
+	for i, word := range words {
+		if paramRegex.MatchString(word) {
+			words[i] = "[]"
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,78 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/config"
+)
+
+// routerPlugin creates a plugin with a single command for the template
+func routerPlugin(name, command, template string) IgorPlugin {
+	languages := map[string]config.LanguagePluginDetails{
+		"english.yml": {Commands: map[string]config.LanguagePluginCommandDetails{
+			command: {Command: template},
+		}},
+	}
+	return HelpPlugin{BasePlugin{name: name, languages: languages}}
+}
+
+func TestCompileTemplate(t *testing.T) {
+	var templateTests = []struct {
+		template string
+		message  string
+		args     []string
+	}{
+		{"remember [name] [url]", "remember cat http://example.com/cat.gif", []string{"cat", "http://example.com/cat.gif"}},
+		{"remember [name] [url]", "remember cat", []string{"cat", ""}},
+		{"remember [name] [url]", "remember", []string{"", ""}},
+		{"say [what] loudly", "say hello there loudly", []string{"hello there"}},
+		{"[anything]", "some text", []string{"some text"}},
+		{"who am I?", "Who am I?", []string{}},
+		{"who am I?", "who am I", nil},
+		{"[anything]", "", nil},
+	}
+	for _, tt := range templateTests {
+		values := compileTemplate(tt.template).pattern.FindStringSubmatch(tt.message)
+		if tt.args == nil {
+			if values != nil {
+				t.Errorf("%v: expected %v not to match", tt.template, tt.message)
+			}
+			continue
+		}
+		if values == nil || len(values[1:]) != len(tt.args) {
+			t.Errorf("%v: expected %v to match with %v, actual %v", tt.template, tt.message, tt.args, values)
+			continue
+		}
+		for i, arg := range tt.args {
+			if values[i+1] != arg {
+				t.Errorf("%v: expected %v to match with %v, actual %v", tt.template, tt.message, tt.args, values[1:])
+			}
+		}
+	}
+}
+
+func TestRouterPriority(t *testing.T) {
+	plugins := map[string]IgorPlugin{
+		"first":  routerPlugin("first", "dice", "roll [dice]"),
+		"second": routerPlugin("second", "roll", "roll [number]"),
+		"third":  routerPlugin("third", "barrel", "roll barrel"),
+	}
+	ambiguities := findAmbiguities(plugins, config.Config{})
+	if len(ambiguities) != 1 || len(ambiguities[0].Routes) != 2 || ambiguities[0].Routes[0].Plugin != "first" {
+		t.Fatalf("Expected the first and second plugin to be ambiguous, actual %v", ambiguities)
+	}
+	if matches := matchRoutes(plugins, "roll barrel", config.Config{}); matches[0].Route.Plugin != "third" {
+		t.Error("Expected the most specific command to be used")
+	}
+	if matches := matchRoutes(plugins, "roll 2d6", config.Config{}); matches[0].Route.Plugin != "first" {
+		t.Error("Expected ambiguous commands to be decided by plugin name")
+	}
+
+	prioritised := config.Config{Priority: []string{"second"}}
+	if ambiguities := findAmbiguities(plugins, prioritised); len(ambiguities) != 0 {
+		t.Errorf("Expected the priority to resolve the ambiguity, actual %v", ambiguities)
+	}
+	if matches := matchRoutes(plugins, "roll barrel", prioritised); matches[0].Route.Plugin != "second" {
+		t.Error("Expected the prioritised plugin to be used")
+	}
+}
@@ -0,0 +1,90 @@
+package plugins_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/plugins"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// routerConfig configures the plugins that need settings to be activated
+const routerConfig = `{"token": "testtoken", "languagedir": "../language",
+	"weather": {"apitoken": "testtoken"},
+	"randomtumblr": {"devops": {"name": "DevOps Reactions", "url": "http://devopsreactions.tumblr.com"}}}`
+
+func TestMatchingPlugins(t *testing.T) {
+	err := os.Setenv("IGOR_CONFIG", routerConfig)
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	generalConfig, err := config.GeneralConfig()
+	if err != nil {
+		t.Fatal("Problem getting config")
+	}
+	var matchTests = []struct {
+		text     string
+		plugin   string
+		command  string
+		language string
+		args     []string
+	}{
+		{"help", "help", "help", "english.yml", []string{}},
+		{"status", "status", "status", "english.yml", []string{}},
+		{"status aws", "status", "status_aws", "english.yml", []string{}},
+		{"STATUS AWS", "status", "status_aws", "english.yml", []string{}},
+		{"status github", "status", "status_service", "english.yml", []string{"github"}},
+		{"statusrapport aws", "status", "status_aws", "nederlands.yml", []string{}},
+		{"xkcd", "xkcd", "xkcd", "english.yml", []string{}},
+		{"xkcd random", "xkcd", "xkcd_random", "english.yml", []string{}},
+		{"xkcd 327", "xkcd", "xkcd_specific", "english.yml", []string{"327"}},
+		{"weather", "weather", "weather", "english.yml", []string{""}},
+		{"weather new  york", "weather", "weather", "english.yml", []string{"new  york"}},
+		{"tumblr devops", "tumblr", "specifictumblr", "english.yml", []string{"devops"}},
+		{"who am I?", "help", "whoami", "english.yml", []string{}},
+	}
+	for _, tt := range matchTests {
+		// Run every test several times to ensure the result doesn't change
+		for i := 0; i < 10; i++ {
+			matches := plugins.MatchingPlugins(slack.Request{Text: tt.text}, generalConfig)
+			if len(matches) == 0 {
+				t.Fatalf("Expected a match for %v", tt.text)
+			}
+			route := matches[0].Route
+			if route.Plugin != tt.plugin || route.Command != tt.command || route.Language != tt.language {
+				t.Fatalf("%v: expected %v/%v (%v), actual %v/%v (%v)", tt.text,
+					tt.plugin, tt.command, tt.language, route.Plugin, route.Command, route.Language)
+			}
+			if len(matches[0].Args) != len(tt.args) {
+				t.Fatalf("%v: expected arguments %v, actual %v", tt.text, tt.args, matches[0].Args)
+			}
+			for j, arg := range tt.args {
+				if matches[0].Args[j] != arg {
+					t.Errorf("%v: expected arguments %v, actual %v", tt.text, tt.args, matches[0].Args)
+				}
+			}
+		}
+	}
+
+	if matches := plugins.MatchingPlugins(slack.Request{Text: "weatherman"}, generalConfig); len(matches) != 0 {
+		t.Error("Expected commands to only match whole words")
+	}
+	if matches := plugins.MatchingPlugins(slack.Request{Text: "not a command"}, generalConfig); len(matches) != 0 {
+		t.Error("Expected no matches for an unknown command")
+	}
+}
+
+func TestAmbiguities(t *testing.T) {
+	err := os.Setenv("IGOR_CONFIG", routerConfig)
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	generalConfig, err := config.GeneralConfig()
+	if err != nil {
+		t.Fatal("Problem getting config")
+	}
+	for _, ambiguity := range plugins.Ambiguities(generalConfig) {
+		t.Errorf("Unexpected ambiguity: %s", ambiguity)
+	}
+}
@@ -0,0 +1,54 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/logging"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// PanicError indicates a plugin panicked while handling a request
+type PanicError struct {
+	Plugin string
+	Value  interface{}
+}
+
+// Error returns a string interpretation of the PanicError
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("Plugin %s panicked: %v", e.Plugin, e.Value)
+}
+
+// Run does the work for a plugin with a deadline. It returns when the work is
+// done, or with the context's error if the deadline passes or the context is
+// cancelled first. A panic in the plugin is recovered and returned as a
+// PanicError, so it doesn't affect other requests.
+func Run(ctx context.Context, name string, timeout time.Duration, work func(context.Context) (slack.Response, error)) (slack.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	type result struct {
+		response slack.Response
+		err      error
+	}
+	// Buffered, so the work can finish after Run has stopped waiting
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if value := recover(); value != nil {
+				logging.FromContext(ctx).With(logging.Fields{"stack": string(debug.Stack())}).Error("Plugin "+name+" panicked", fmt.Errorf("%v", value))
+				done <- result{err: &PanicError{Plugin: name, Value: value}}
+			}
+		}()
+		response, err := work(ctx)
+		done <- result{response: response, err: err}
+	}()
+	select {
+	case result := <-done:
+		return result.response, result.err
+	case <-ctx.Done():
+		logging.FromContext(ctx).Error("Plugin "+name+" stopped", ctx.Err())
+		return slack.Response{}, ctx.Err()
+	}
+}
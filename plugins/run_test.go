@@ -0,0 +1,37 @@
+package plugins_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/plugins"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestRun(t *testing.T) {
+	response, err := plugins.Run(context.Background(), "test", time.Second, func(ctx context.Context) (slack.Response, error) {
+		return slack.Response{Text: "done"}, nil
+	})
+	if err != nil || response.Text != "done" {
+		t.Errorf("Expected the plugin's response, actual %v (%v)", response, err)
+	}
+
+	_, err = plugins.Run(context.Background(), "test", time.Second, func(ctx context.Context) (slack.Response, error) {
+		panic("something broke")
+	})
+	var panicErr *plugins.PanicError
+	if !errors.As(err, &panicErr) || panicErr.Plugin != "test" {
+		t.Errorf("Expected a PanicError, actual %v", err)
+	}
+
+	_, err = plugins.Run(context.Background(), "test", 10*time.Millisecond, func(ctx context.Context) (slack.Response, error) {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		return slack.Response{}, nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to be exceeded, actual %v", err)
+	}
+}
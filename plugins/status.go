@@ -1,63 +1,77 @@
 package plugins
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 
 	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/logging"
 	"github.com/ArjenSchwarz/igor/slack"
 )
 
 // StatusPlugin provides status reports for various services
 type StatusPlugin struct {
-	name        string
-	description string
-	config      statusConfig
-	Checks      map[string]func() (slack.Attachment, error)
-	MainChecks  map[string]func() (slack.Attachment, error)
-	request     slack.Request
+	BasePlugin
+	config     statusConfig
+	upstreams  map[string]Upstream
+	Checks     map[string]func(context.Context) (slack.Attachment, error)
+	MainChecks map[string]func(context.Context) (slack.Attachment, error)
 }
 
 type statusConfig struct {
-	Main           []string
-	languages      map[string]config.LanguagePluginDetails
-	chosenLanguage string
+	Main []string
 }
 
-// Config returns the plugin configuration
-func (plugin StatusPlugin) Config() IgorConfig {
-	return plugin.config
-}
-
-func (config statusConfig) Languages() map[string]config.LanguagePluginDetails {
-	return config.languages
+func init() {
+	Register(Registration{Name: "status", Section: "status", Factory: Status})
+	// Status pages are cached briefly, so a channel asking at the same
+	// time doesn't check every service again
+	for name, base := range statusUpstreams {
+		registerUpstream(name, Upstream{URL: base, Retries: DefaultUpstreamRetries, Cache: 30 * time.Second})
+	}
 }
 
-func (config statusConfig) ChosenLanguage() string {
-	return config.chosenLanguage
+// statusUpstreams contains the services the status is checked with, and
+// their status pages
+var statusUpstreams = map[string]string{
+	"isitup":     "https://isitup.org/",
+	"github":     "https://status.github.com/",
+	"bitbucket":  "http://status.bitbucket.org",
+	"npmjs":      "http://status.npmjs.org",
+	"disqus":     "http://status.disqus.com",
+	"cloudflare": "http://cloudflarestatus.com",
+	"travis":     "https://www.traviscistatus.com",
+	"aws":        "http://status.aws.amazon.com",
+	"docker":     "http://status.status.io",
 }
 
 // Status instantiates the StatusPlugin
 func Status(request slack.Request) (IgorPlugin, error) {
-	pluginName := "status"
-	pluginConfig, err := parseStatusConfig()
+	pluginConfig, err := parseStatusConfig(request.TeamID)
+	if err != nil {
+		return StatusPlugin{}, err
+	}
+	names := []string{}
+	for name := range statusUpstreams {
+		names = append(names, name)
+	}
+	upstreams, err := teamUpstreams(request.TeamID, names...)
 	if err != nil {
 		return StatusPlugin{}, err
 	}
-	pluginConfig.languages = getPluginLanguages(pluginName)
 	plugin := StatusPlugin{
-		name:        pluginName,
-		description: "",
-		config:      pluginConfig,
-		request:     request,
+		BasePlugin: NewBasePlugin("status", request),
+		config:     pluginConfig,
+		upstreams:  upstreams,
 	}
-	statuschecks := make(map[string]func() (slack.Attachment, error))
+	statuschecks := make(map[string]func(context.Context) (slack.Attachment, error))
 	statuschecks["github"] = plugin.handleGitHubStatus
 	statuschecks["bitbucket"] = plugin.handleBitbucketStatus
 	statuschecks["npmjs"] = plugin.handleNpmjsStatus
@@ -71,7 +85,7 @@ func Status(request slack.Request) (IgorPlugin, error) {
 	if len(pluginConfig.Main) == 0 {
 		plugin.MainChecks = statuschecks
 	} else {
-		mainchecks := make(map[string]func() (slack.Attachment, error))
+		mainchecks := make(map[string]func(context.Context) (slack.Attachment, error))
 		for _, check := range pluginConfig.Main {
 			if val, ok := statuschecks[check]; ok {
 				mainchecks[check] = val
@@ -85,16 +99,20 @@ func Status(request slack.Request) (IgorPlugin, error) {
 
 // Work parses the request and ensures a request comes through if any triggers
 // are matched. Handled triggers:
-func (plugin StatusPlugin) Work() (slack.Response, error) {
+func (plugin StatusPlugin) Work(ctx context.Context) (slack.Response, error) {
 	statuschecks := plugin.Checks
 	response := slack.Response{}
-	message, language := getCommandName(plugin)
-	plugin.config.chosenLanguage = language
+	command, err := getCommand(plugin, statusParams)
+	if err != nil {
+		return response, err
+	}
+	message := command.Name
+	plugin.chosenLanguage = command.Language
 	if message == "status" {
 		c := make(chan slack.Attachment)
 		for _, function := range plugin.MainChecks {
-			go func(function func() (slack.Attachment, error)) {
-				attachment, err := function()
+			go func(function func(context.Context) (slack.Attachment, error)) {
+				attachment, err := function(ctx)
 				if err != nil {
 					// return response, err
 				}
@@ -108,7 +126,7 @@ func (plugin StatusPlugin) Work() (slack.Response, error) {
 		response.Text = commandDetails.Texts["response_text"]
 		response.SetPublic()
 	} else if message == "status_aws" {
-		attachments, _ := plugin.handleAWSStatus()
+		attachments, _ := plugin.handleAWSStatus(ctx)
 		for _, attachment := range attachments {
 			response.AddAttachment(attachment)
 		}
@@ -116,17 +134,13 @@ func (plugin StatusPlugin) Work() (slack.Response, error) {
 		response.Text = commandDetails.Texts["response_text"]
 		response.SetPublic()
 	} else if message == "status_service" || message == "status_url" {
-		parts := strings.Split(plugin.Message(), " ")
-		tocheck := ""
-		if len(parts) > 1 {
-			tocheck = strings.TrimSpace(strings.Replace(plugin.Message(), parts[0], "", 1))
-		}
+		tocheck := command.Args.String("target")
 		// Check if this is a predefined service
 		if function, ok := statuschecks[tocheck]; ok {
 			// Treat it as a predefined service
-			attachment, err := function()
+			attachment, err := function(ctx)
 			if err != nil {
-				fmt.Println(err)
+				logging.FromContext(ctx).With(logging.Fields{"service": tocheck}).Error("Status check failed", err)
 				return response, err
 			}
 			response.AddAttachment(attachment)
@@ -136,7 +150,7 @@ func (plugin StatusPlugin) Work() (slack.Response, error) {
 			response.SetPublic()
 		} else {
 			// Treat it as a website
-			attachment, err := plugin.handleDomain(plugin.Message()[7:])
+			attachment, err := plugin.handleDomain(ctx, tocheck)
 			if err != nil {
 				return response, err
 			}
@@ -149,9 +163,47 @@ func (plugin StatusPlugin) Work() (slack.Response, error) {
 	if response.Text == "" {
 		return response, CreateNoMatchError("Nothing found")
 	}
+	addStatusBlocks(&response)
 	return response, nil
 }
 
+// statusParams contains the parameters of the commands. Services and
+// websites share the same parameter, as what's provided decides which of the
+// two it is.
+var statusParams = map[string][]Param{
+	"status_url":     {{Name: "target", Type: StringParam}},
+	"status_service": {{Name: "target", Type: StringParam}},
+}
+
+// addStatusBlocks adds the Block Kit version of the status results to the
+// response, as a list with an indicator for each service's status
+func addStatusBlocks(response *slack.Response) {
+	response.AddBlock(slack.NewSectionBlock(slack.Markdown(response.Text)))
+	for _, attach := range response.Attachments {
+		text := fmt.Sprintf("%s *%s*", statusIndicator(attach.Color), attach.Title)
+		if attach.Text != "" {
+			text += "\n" + attach.Text
+		}
+		response.AddBlock(slack.NewSectionBlock(slack.Markdown(text)))
+		if attach.PreText != "" {
+			response.AddBlock(slack.NewContextBlock(slack.Markdown(attach.PreText)))
+		}
+	}
+}
+
+// statusIndicator returns the emoji matching the color of a status
+func statusIndicator(color string) string {
+	switch color {
+	case slack.ResponseGood:
+		return ":large_green_circle:"
+	case slack.ResponseWarning:
+		return ":large_yellow_circle:"
+	case slack.ResponseBad:
+		return ":red_circle:"
+	}
+	return ":white_circle:"
+}
+
 // Describe provides the triggers StatusPlugin can handle
 func (plugin StatusPlugin) Describe(language string) map[string]string {
 	// Get a list of all services
@@ -173,33 +225,13 @@ func (plugin StatusPlugin) Describe(language string) map[string]string {
 	return descriptions
 }
 
-// Description returns a global description of the plugin
-func (plugin StatusPlugin) Description(language string) string {
-	return getDescriptionText(plugin, language)
-}
-
-// Name returns the name of the plugin
-func (plugin StatusPlugin) Name() string {
-	return plugin.name
-}
-
-// Message returns a formatted version of the original message
-func (plugin StatusPlugin) Message() string {
-	return plugin.request.Text
-}
-
-func (plugin StatusPlugin) handleDomain(domain string) (slack.Attachment, error) {
+func (plugin StatusPlugin) handleDomain(ctx context.Context, domain string) (slack.Attachment, error) {
 	attachment := slack.Attachment{Title: domain}
 	commandDetails := getCommandDetails(plugin, "status_url")
-	resp, err := http.Get(fmt.Sprintf("https://isitup.org/%s.json", domain))
-	defer resp.Body.Close()
-	if err != nil {
-		return attachment, err
-	}
 	var result struct {
 		StatusCode int64 `json:"status_code"`
 	}
-	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := getJSON(ctx, plugin.upstreams["isitup"], url.PathEscape(domain)+".json", &result); err != nil {
 		return attachment, err
 	}
 	switch result.StatusCode {
@@ -215,18 +247,13 @@ func (plugin StatusPlugin) handleDomain(domain string) (slack.Attachment, error)
 	return attachment, nil
 }
 
-func (StatusPlugin) handleGitHubStatus() (slack.Attachment, error) {
-	attachment := slack.Attachment{Title: "GitHub", PreText: "http://status.github.com"}
-	resp, err := http.Get("https://status.github.com/api/last-message.json")
-	defer resp.Body.Close()
-	if err != nil {
-		return attachment, err
-	}
+func (plugin StatusPlugin) handleGitHubStatus(ctx context.Context) (slack.Attachment, error) {
+	attachment := slack.Attachment{Title: "GitHub", PreText: plugin.upstreams["github"].URL}
 	var result struct {
 		Status string `json:"status"`
 		Body   string `json:"body"`
 	}
-	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := getJSON(ctx, plugin.upstreams["github"], "api/last-message.json", &result); err != nil {
 		return attachment, err
 	}
 	attachment.Text = result.Body
@@ -241,41 +268,41 @@ func (StatusPlugin) handleGitHubStatus() (slack.Attachment, error) {
 	return attachment, nil
 }
 
-func (plugin StatusPlugin) handleBitbucketStatus() (slack.Attachment, error) {
-	attachment := slack.Attachment{Title: "Bitbucket", PreText: "http://status.bitbucket.org"}
-	return plugin.handleStatusPageIo(attachment)
+func (plugin StatusPlugin) handleBitbucketStatus(ctx context.Context) (slack.Attachment, error) {
+	attachment := slack.Attachment{Title: "Bitbucket", PreText: plugin.upstreams["bitbucket"].URL}
+	return plugin.handleStatusPageIo(ctx, plugin.upstreams["bitbucket"], attachment)
 }
 
-func (plugin StatusPlugin) handleNpmjsStatus() (slack.Attachment, error) {
-	attachment := slack.Attachment{Title: "NPM", PreText: "http://status.npmjs.org"}
-	return plugin.handleStatusPageIo(attachment)
+func (plugin StatusPlugin) handleNpmjsStatus(ctx context.Context) (slack.Attachment, error) {
+	attachment := slack.Attachment{Title: "NPM", PreText: plugin.upstreams["npmjs"].URL}
+	return plugin.handleStatusPageIo(ctx, plugin.upstreams["npmjs"], attachment)
 }
 
-func (plugin StatusPlugin) handleDisqusStatus() (slack.Attachment, error) {
-	attachment := slack.Attachment{Title: "Disqus", PreText: "http://status.disqus.com"}
-	return plugin.handleStatusPageIo(attachment)
+func (plugin StatusPlugin) handleDisqusStatus(ctx context.Context) (slack.Attachment, error) {
+	attachment := slack.Attachment{Title: "Disqus", PreText: plugin.upstreams["disqus"].URL}
+	return plugin.handleStatusPageIo(ctx, plugin.upstreams["disqus"], attachment)
 }
 
-func (plugin StatusPlugin) handleCloudflareStatus() (slack.Attachment, error) {
-	attachment := slack.Attachment{Title: "Cloudflare", PreText: "http://cloudflarestatus.com"}
-	return plugin.handleStatusPageIo(attachment)
+func (plugin StatusPlugin) handleCloudflareStatus(ctx context.Context) (slack.Attachment, error) {
+	attachment := slack.Attachment{Title: "Cloudflare", PreText: plugin.upstreams["cloudflare"].URL}
+	return plugin.handleStatusPageIo(ctx, plugin.upstreams["cloudflare"], attachment)
 }
 
-func (plugin StatusPlugin) handleTravisCIStatus() (slack.Attachment, error) {
-	attachment := slack.Attachment{Title: "Travis CI", PreText: "https://www.traviscistatus.com"}
-	return plugin.handleStatusPageIo(attachment)
+func (plugin StatusPlugin) handleTravisCIStatus(ctx context.Context) (slack.Attachment, error) {
+	attachment := slack.Attachment{Title: "Travis CI", PreText: plugin.upstreams["travis"].URL}
+	return plugin.handleStatusPageIo(ctx, plugin.upstreams["travis"], attachment)
 }
 
-func (plugin StatusPlugin) handleAWSStatus() ([]slack.Attachment, error) {
+func (plugin StatusPlugin) handleAWSStatus(ctx context.Context) ([]slack.Attachment, error) {
 	attachments := []slack.Attachment{}
-	mainAttachment := slack.Attachment{Title: "AWS", PreText: "http://status.aws.amazon.com"}
+	mainAttachment := slack.Attachment{Title: "AWS", PreText: plugin.upstreams["aws"].URL}
 	attachments = append(attachments, mainAttachment)
 	nrResolved := 0
 	nrProblems := 0
 
 	commandDetails := getCommandDetails(plugin, "status_aws")
 
-	doc, err := goquery.NewDocument(mainAttachment.PreText)
+	doc, err := getDocument(ctx, plugin.upstreams["aws"], "")
 	if err != nil {
 		return attachments, err
 	}
@@ -314,13 +341,13 @@ func (plugin StatusPlugin) handleAWSStatus() ([]slack.Attachment, error) {
 	return attachments, nil
 }
 
-func (plugin StatusPlugin) handleShortAWSStatus() (slack.Attachment, error) {
-	attachment := slack.Attachment{Title: "AWS", PreText: "http://status.aws.amazon.com"}
+func (plugin StatusPlugin) handleShortAWSStatus(ctx context.Context) (slack.Attachment, error) {
+	attachment := slack.Attachment{Title: "AWS", PreText: plugin.upstreams["aws"].URL}
 	nrResolved := 0
 	nrProblems := 0
 	commandDetails := getCommandDetails(plugin, "status_aws")
 
-	doc, err := goquery.NewDocument(attachment.PreText)
+	doc, err := getDocument(ctx, plugin.upstreams["aws"], "")
 	if err != nil {
 		return attachment, err
 	}
@@ -355,13 +382,13 @@ func (plugin StatusPlugin) handleShortAWSStatus() (slack.Attachment, error) {
 	return attachment, nil
 }
 
-func (plugin StatusPlugin) handleDockerStatus() (slack.Attachment, error) {
-	attachment := slack.Attachment{Title: "Docker", PreText: "http://status.status.io"}
-	return plugin.handleStatusIo(attachment)
+func (plugin StatusPlugin) handleDockerStatus(ctx context.Context) (slack.Attachment, error) {
+	attachment := slack.Attachment{Title: "Docker", PreText: plugin.upstreams["docker"].URL}
+	return plugin.handleStatusIo(ctx, plugin.upstreams["docker"], attachment)
 }
 
-func (StatusPlugin) handleStatusPageIo(attachment slack.Attachment) (slack.Attachment, error) {
-	doc, err := goquery.NewDocument(attachment.PreText)
+func (StatusPlugin) handleStatusPageIo(ctx context.Context, upstream Upstream, attachment slack.Attachment) (slack.Attachment, error) {
+	doc, err := getDocument(ctx, upstream, "")
 	if err != nil {
 		return attachment, err
 	}
@@ -377,8 +404,8 @@ func (StatusPlugin) handleStatusPageIo(attachment slack.Attachment) (slack.Attac
 	return attachment, nil
 }
 
-func (StatusPlugin) handleStatusIo(attachment slack.Attachment) (slack.Attachment, error) {
-	doc, err := goquery.NewDocument(attachment.PreText)
+func (StatusPlugin) handleStatusIo(ctx context.Context, upstream Upstream, attachment slack.Attachment) (slack.Attachment, error) {
+	doc, err := getDocument(ctx, upstream, "")
 	if err != nil {
 		return attachment, err
 	}
@@ -391,11 +418,11 @@ func (StatusPlugin) handleStatusIo(attachment slack.Attachment) (slack.Attachmen
 	return attachment, nil
 }
 
-func parseStatusConfig() (statusConfig, error) {
+func parseStatusConfig(teamID string) (statusConfig, error) {
 	pluginConfig := struct {
 		Status statusConfig
 	}{}
 
-	err := config.ParseConfig(&pluginConfig)
+	err := config.ParseTeamConfig(teamID, &pluginConfig)
 	return pluginConfig.Status, err
 }
@@ -0,0 +1,74 @@
+package plugins
+
+import (
+	"encoding/json"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// triggerInput is what's sent to plugins defined in the configuration, like
+// exec and webhook plugins, when one of their triggers matches
+type triggerInput struct {
+	Request   slack.Request     `json:"request"`
+	Command   string            `json:"command"`
+	Language  string            `json:"language"`
+	Arguments map[string]string `json:"arguments"`
+}
+
+// newTriggerInput creates the input for the matched command. The
+// response_url lets anyone post in the channel for a while, so it's only
+// shared when the plugin's configuration asks for it.
+func newTriggerInput(request slack.Request, command Command, shareResponseURL bool) triggerInput {
+	// There's no need to share the verification token
+	request.Token = ""
+	if !shareResponseURL {
+		request.ResponseURL = ""
+	}
+	input := triggerInput{
+		Request:   request,
+		Command:   command.Name,
+		Language:  command.Language,
+		Arguments: make(map[string]string),
+	}
+	for name := range command.Args {
+		input.Arguments[name] = command.Args.String(name)
+	}
+	return input
+}
+
+// triggerLanguages provides the configured triggers as the commands of a
+// plugin in the default language of the request's team
+func triggerLanguages(request slack.Request, description string, triggers map[string]config.LanguagePluginCommandDetails) map[string]config.LanguagePluginDetails {
+	teamConfig, _ := config.TeamConfig(request.TeamID)
+	return map[string]config.LanguagePluginDetails{
+		teamConfig.DefaultLanguage: {
+			Description: description,
+			Commands:    triggers,
+		},
+	}
+}
+
+// triggerParams makes every placeholder in the triggers an optional
+// parameter, so the plugin's program or service can decide what to do with
+// them
+func triggerParams(triggers map[string]config.LanguagePluginCommandDetails) map[string][]Param {
+	params := make(map[string][]Param)
+	for name, trigger := range triggers {
+		for _, placeholder := range compileTemplate(trigger.Command).params {
+			params[name] = append(params[name], Param{Name: placeholder, Type: RestParam, Optional: true})
+		}
+	}
+	return params
+}
+
+// decodeResponse decodes a response in the Slack format. Blocks can't be
+// decoded into the Block interface, so they're ignored.
+func decodeResponse(body []byte) (slack.Response, error) {
+	output := struct {
+		slack.Response
+		Blocks json.RawMessage `json:"blocks"`
+	}{}
+	err := json.Unmarshal(body, &output)
+	return output.Response, err
+}
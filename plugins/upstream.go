@@ -0,0 +1,279 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"github.com/ArjenSchwarz/igor/config"
+)
+
+// Upstream is a service that plugins retrieve information from. Plugins
+// register the services they use with the settings that suit them, and the
+// upstreams section of the configuration can change these.
+type Upstream struct {
+	// Name is the name the upstream is registered with
+	Name string
+	// URL is the base URL of the service. Paths are retrieved relative to
+	// it, so it can be replaced with a local stand-in.
+	URL string
+	// Timeout is how long a single attempt can take
+	Timeout time.Duration
+	// Retries is how many times a request is tried again when the service
+	// can't be reached or has a server error
+	Retries int
+	// Cache is how long successful responses are reused, nothing is cached
+	// when it's 0
+	Cache time.Duration
+}
+
+// The settings for upstreams that don't have their own
+const (
+	DefaultUpstreamTimeout = 10 * time.Second
+	DefaultUpstreamRetries = 2
+)
+
+// UserAgent is sent with every request to an upstream
+const UserAgent = "Igor (+https://github.com/ArjenSchwarz/igor)"
+
+// upstreamBackoff is how long to wait before the first retry, every next
+// retry waits twice as long
+var upstreamBackoff = 200 * time.Millisecond
+
+// maxUpstreamResponse is the largest response body that's read
+const maxUpstreamResponse = 10 * 1024 * 1024
+
+var (
+	upstreams     = map[string]Upstream{}
+	upstreamCache = make(map[string]cachedResponse)
+	cacheLock     sync.Mutex
+)
+
+// upstreamResponse is a response read from an upstream
+type upstreamResponse struct {
+	StatusCode int
+	Body       []byte
+	// URL is the URL the response came from, after following redirects
+	URL *url.URL
+}
+
+type cachedResponse struct {
+	response upstreamResponse
+	expires  time.Time
+}
+
+// registerUpstream adds a service plugins use, with its default settings
+func registerUpstream(name string, upstream Upstream) {
+	upstreams[name] = upstream
+}
+
+// upstreamConfig contains the settings of an upstream in the configuration.
+// The timeout and cache are durations like "10s".
+type upstreamConfig struct {
+	URL     string
+	Timeout string
+	Retries *int
+	Cache   string
+}
+
+// teamUpstreams returns the settings of the upstreams a plugin uses, for
+// the team. Plugins resolve these when they're created, like their other
+// settings, so a team can have its own upstreams section.
+func teamUpstreams(teamID string, names ...string) (map[string]Upstream, error) {
+	settings := struct {
+		Upstreams map[string]upstreamConfig
+	}{}
+	if err := config.ParseTeamConfig(teamID, &settings); err != nil {
+		return nil, err
+	}
+	resolved := make(map[string]Upstream)
+	for _, name := range names {
+		upstream, err := upstreamSettings(name, settings.Upstreams)
+		if err != nil {
+			return nil, err
+		}
+		resolved[name] = upstream
+	}
+	return resolved, nil
+}
+
+// upstreamSettings returns the settings for the upstream. The registered
+// settings are changed by the configuration's default upstream, and then by
+// the upstream's own configuration. Durations that can't be parsed are an
+// error.
+func upstreamSettings(name string, configuredUpstreams map[string]upstreamConfig) (Upstream, error) {
+	upstream, registered := upstreams[name]
+	upstream.Name = name
+	if upstream.Timeout == 0 {
+		upstream.Timeout = DefaultUpstreamTimeout
+	}
+	if !registered {
+		upstream.Retries = DefaultUpstreamRetries
+	}
+	for _, key := range []string{"default", name} {
+		configured, ok := configuredUpstreams[key]
+		if !ok {
+			continue
+		}
+		if configured.URL != "" && key == name {
+			upstream.URL = configured.URL
+		}
+		if configured.Timeout != "" {
+			timeout, err := time.ParseDuration(configured.Timeout)
+			if err != nil || timeout <= 0 {
+				return upstream, fmt.Errorf("the timeout of upstream %s isn't a valid duration: %q", key, configured.Timeout)
+			}
+			upstream.Timeout = timeout
+		}
+		if configured.Retries != nil {
+			upstream.Retries = *configured.Retries
+		}
+		if configured.Cache != "" {
+			cache, err := time.ParseDuration(configured.Cache)
+			if err != nil {
+				return upstream, fmt.Errorf("the cache of upstream %s isn't a valid duration: %q", key, configured.Cache)
+			}
+			upstream.Cache = cache
+		}
+	}
+	return upstream, nil
+}
+
+// url returns the URL for the path on the upstream. Paths that are full
+// URLs, like those built from a plugin's own settings, are used as is.
+func (upstream Upstream) url(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	if path == "" {
+		return upstream.URL
+	}
+	return strings.TrimSuffix(upstream.URL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// fetch retrieves the path from the upstream. Every attempt has the
+// upstream's timeout, and requests that fail or get a server error are
+// retried with an increasing delay. Successful responses are cached for as
+// long as the upstream allows. Other responses are returned as they are,
+// the caller decides what their status means.
+func fetch(ctx context.Context, upstream Upstream, path string) (upstreamResponse, error) {
+	target := upstream.url(path)
+	if upstream.Cache > 0 {
+		if response, ok := cachedUpstream(target); ok {
+			return response, nil
+		}
+	}
+	backoff := upstreamBackoff
+	var response upstreamResponse
+	var err error
+	for attempt := 0; attempt <= upstream.Retries; attempt++ {
+		if attempt != 0 {
+			select {
+			case <-ctx.Done():
+				return response, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		response, err = fetchOnce(ctx, target, upstream.Timeout)
+		if err == nil && response.StatusCode < 500 && response.StatusCode != http.StatusTooManyRequests {
+			break
+		}
+	}
+	if err != nil {
+		return response, err
+	}
+	if upstream.Cache > 0 && response.StatusCode == http.StatusOK {
+		cacheUpstream(target, response, upstream.Cache)
+	}
+	return response, nil
+}
+
+// fetchOnce makes a single attempt at retrieving the URL, and reads the
+// response within the timeout
+func fetchOnce(ctx context.Context, target string, timeout time.Duration) (upstreamResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return upstreamResponse{}, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return upstreamResponse{}, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxUpstreamResponse))
+	if err != nil {
+		return upstreamResponse{}, err
+	}
+	return upstreamResponse{StatusCode: resp.StatusCode, Body: body, URL: resp.Request.URL}, nil
+}
+
+// cachedUpstream returns the cached response for the URL, if it hasn't
+// expired yet
+func cachedUpstream(target string) (upstreamResponse, bool) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	cached, ok := upstreamCache[target]
+	if !ok || time.Now().After(cached.expires) {
+		return upstreamResponse{}, false
+	}
+	return cached.response, true
+}
+
+// cacheUpstream keeps the response for the URL, and forgets the responses
+// that have expired
+func cacheUpstream(target string, response upstreamResponse, ttl time.Duration) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	now := time.Now()
+	for key, cached := range upstreamCache {
+		if now.After(cached.expires) {
+			delete(upstreamCache, key)
+		}
+	}
+	upstreamCache[target] = cachedResponse{response: response, expires: now.Add(ttl)}
+}
+
+// getJSON retrieves the path from the upstream and decodes the JSON
+// response into the value. Responses that aren't successful are an error.
+func getJSON(ctx context.Context, upstream Upstream, path string, value interface{}) error {
+	response, err := fetch(ctx, upstream, path)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s responded with status %d", upstream.Name, response.StatusCode)
+	}
+	return json.Unmarshal(response.Body, value)
+}
+
+// getDocument retrieves the path from the upstream and parses the HTML
+// document. Responses that aren't successful are an error.
+func getDocument(ctx context.Context, upstream Upstream, path string) (*goquery.Document, error) {
+	response, err := fetch(ctx, upstream, path)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("%s responded with status %d", upstream.Name, response.StatusCode)
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(response.Body))
+	if err != nil {
+		return nil, err
+	}
+	doc.Url = response.URL
+	return doc, nil
+}
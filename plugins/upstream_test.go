@@ -0,0 +1,93 @@
+package plugins_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/plugins"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestUpstreamStandIn(t *testing.T) {
+	var lock sync.Mutex
+	hits := make(map[string]int)
+	standIn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits[r.URL.Path]++
+		hit := hits[r.URL.Path]
+		lock.Unlock()
+		if !strings.HasPrefix(r.UserAgent(), "Igor") {
+			t.Errorf("Expected Igor's User-Agent, actual %q", r.UserAgent())
+		}
+		switch {
+		case r.URL.Path == "/5/info.0.json" && hit == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/5/info.0.json":
+			fmt.Fprint(w, `{"num": 5, "title": "Blown apart", "alt": "Blown apart", "img": "https://imgs.xkcd.com/comics/blownapart_color.jpg"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer standIn.Close()
+	err := os.Setenv("IGOR_CONFIG", `{"token": "testtoken", "languagedir": "../language",
+		"upstreams": {"xkcd": {"url": "`+standIn.URL+`/", "retries": 1}}}`)
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	for i := 0; i < 2; i++ {
+		plugin, _ := plugins.Xkcd(slack.Request{Text: "xkcd 5"})
+		response, err := plugin.Work(context.Background())
+		if err != nil {
+			t.Fatal("Expected the stand-in to be used after a retry", err)
+		}
+		if len(response.Attachments) == 0 || response.Attachments[0].Title != "Blown apart" {
+			t.Errorf("Expected the comic from the stand-in, actual %+v", response)
+		}
+	}
+	if hits["/5/info.0.json"] != 2 {
+		t.Errorf("Expected a retry and then a cached response, actual %d requests", hits["/5/info.0.json"])
+	}
+	plugin, _ := plugins.Xkcd(slack.Request{Text: "xkcd 9999"})
+	if _, err := plugin.Work(context.Background()); err == nil {
+		t.Error("Expected an error for a comic that doesn't exist")
+	}
+	if hits["/9999/info.0.json"] != 1 {
+		t.Errorf("Expected responses that aren't server errors not to be retried, actual %d requests", hits["/9999/info.0.json"])
+	}
+}
+
+func TestUpstreamTeamSettings(t *testing.T) {
+	standIn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"num": 6, "title": "Irony", "alt": "Irony", "img": "https://imgs.xkcd.com/comics/irony_color.jpg"}`)
+	}))
+	defer standIn.Close()
+	err := os.Setenv("IGOR_CONFIG", `{"token": "testtoken", "languagedir": "../language",
+		"upstreams": {"xkcd": {"url": "http://127.0.0.1:1/", "retries": 0}},
+		"teams": {"T1": {"upstreams": {"xkcd": {"url": "`+standIn.URL+`/"}}},
+			"T2": {"upstreams": {"default": {"timeout": "soon"}}}}}`)
+	if err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	plugin, err := plugins.Xkcd(slack.Request{Text: "xkcd 6", TeamID: "T1"})
+	if err != nil {
+		t.Fatal("Unexpected error creating the plugin", err)
+	}
+	response, err := plugin.Work(context.Background())
+	if err != nil || len(response.Attachments) == 0 || response.Attachments[0].Title != "Irony" {
+		t.Fatalf("Expected the team's upstream to be used, actual %+v (%v)", response, err)
+	}
+	for _, action := range response.Attachments[0].Actions {
+		if action.Name == "next" {
+			t.Error("Expected no next button on the latest comic")
+		}
+	}
+	if _, err := plugins.Xkcd(slack.Request{Text: "xkcd 6", TeamID: "T2"}); err == nil {
+		t.Error("Expected an error for a timeout that isn't a duration")
+	}
+}
@@ -1,12 +1,12 @@
 package plugins
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ArjenSchwarz/igor/config"
 	"github.com/ArjenSchwarz/igor/helpers"
@@ -15,99 +15,96 @@ import (
 
 // WeatherPlugin provides weather information for the city you specify
 type WeatherPlugin struct {
-	name        string
-	description string
-	Source      string
-	config      weatherConfig
-	request     slack.Request
+	BasePlugin
+	Source   string
+	config   weatherConfig
+	upstream Upstream
 }
 
-// Config returns the plugin configuration
-func (plugin WeatherPlugin) Config() IgorConfig {
-	return plugin.config
-}
-
-func (config weatherConfig) Languages() map[string]config.LanguagePluginDetails {
-	return config.languages
-}
-
-func (config weatherConfig) ChosenLanguage() string {
-	return config.chosenLanguage
+func init() {
+	Register(Registration{
+		Name:     "weather",
+		Section:  "weather",
+		Required: []string{"weather.apitoken"},
+		Factory:  Weather,
+	})
+	// The weather in a city doesn't change every second, and the API's
+	// free plan has a limit on the number of calls
+	registerUpstream("openweathermap", Upstream{URL: "http://api.openweathermap.org/data/2.5/", Retries: DefaultUpstreamRetries, Cache: 10 * time.Minute})
 }
 
 // Weather instantiates a WeatherPlugin
 func Weather(request slack.Request) (IgorPlugin, error) {
-	pluginName := "weather"
-	pluginConfig, err := parseWeatherConfig()
+	pluginConfig, err := parseWeatherConfig(request.TeamID)
+	if err != nil {
+		return WeatherPlugin{}, err
+	}
+	upstreams, err := teamUpstreams(request.TeamID, "openweathermap")
 	if err != nil {
 		return WeatherPlugin{}, err
 	}
 	plugin := WeatherPlugin{
-		name:    pluginName,
-		Source:  "http://api.openweathermap.org/data/2.5/",
-		config:  pluginConfig,
-		request: request,
+		BasePlugin: NewBasePlugin("weather", request),
+		Source:     upstreams["openweathermap"].URL,
+		config:     pluginConfig,
+		upstream:   upstreams["openweathermap"],
 	}
 	return plugin, nil
 }
 
-// Describe provides the triggers WeatherPlugin can handle
-func (plugin WeatherPlugin) Describe(language string) map[string]string {
-
-	descriptions := make(map[string]string)
-	for _, values := range getAllCommands(plugin, language) {
-		descriptions[values.Command] = values.Description
-	}
-	return descriptions
-}
-
 // Work parses the request and ensures a request comes through if any triggers
 // are matched. Handled triggers:
 //
 // * weather
 // * forecast
-func (plugin WeatherPlugin) Work() (slack.Response, error) {
+func (plugin WeatherPlugin) Work(ctx context.Context) (slack.Response, error) {
 	response := slack.Response{}
-	message, language := getCommandName(plugin)
-	plugin.config.chosenLanguage = language
-	switch message {
+	command, err := getCommand(plugin, weatherParams)
+	if err != nil {
+		return response, err
+	}
+	plugin.chosenLanguage = command.Language
+	switch command.Name {
 	case "weather":
-		return plugin.handleWeather()
+		return plugin.handleWeather(ctx, command.Args)
 	case "forecast":
-		return plugin.handleForecast()
+		return plugin.handleForecast(ctx, command.Args)
 	}
 
 	return response, CreateNoMatchError("Nothing found")
 }
 
+// weatherParams contains the parameters of the commands, the city is
+// optional as it falls back to the default city
+var weatherParams = map[string][]Param{
+	"weather":  {{Name: "city", Type: RestParam, Optional: true}},
+	"forecast": {{Name: "city", Type: RestParam, Optional: true}},
+}
+
+// weatherCity returns the city from the arguments or the default city
+func (plugin *WeatherPlugin) weatherCity(args Args) string {
+	if args.Has("city") {
+		return args.String("city")
+	}
+	return plugin.config.determineDefaultWeatherCity(plugin.request)
+}
+
 // handleWeather handles a request for the current Weather
-func (plugin *WeatherPlugin) handleWeather() (slack.Response, error) {
-	var city string
-	parts := strings.Split(plugin.Message(), " ")
-	if len(parts) > 1 {
-		city = strings.Replace(plugin.Message(), parts[0], "", 1)
-	} else {
-		city = plugin.config.determineDefaultWeatherCity(plugin.request)
-	}
-	city = url.QueryEscape(city)
+func (plugin *WeatherPlugin) handleWeather(ctx context.Context, args Args) (slack.Response, error) {
+	city := url.QueryEscape(plugin.weatherCity(args))
 	if isSpecialWeather(city) {
 		return getSpecialWeather(city)
 	}
 	response := slack.Response{}
 	url := fmt.Sprintf("%sfind?APPID=%s&q=%s&units=%s",
 		plugin.Source, plugin.config.APIToken, city, plugin.config.Units)
-	resp, err := http.Get(url)
-	if err != nil {
-		return response, err
-	}
-	defer resp.Body.Close()
 	parsedResult := weatherResponse{}
-
-	if err := json.NewDecoder(resp.Body).Decode(&parsedResult); err != nil {
+	if err := getJSON(ctx, plugin.upstream, url, &parsedResult); err != nil {
 		return response, err
 	}
 	commandDetails := getCommandDetails(plugin, "weather")
 	response.Text = commandDetails.Texts["response_text"]
+	response.AddBlock(slack.NewSectionBlock(slack.Markdown(response.Text)))
 	for _, record := range parsedResult.List {
 		attach := slack.Attachment{}
 		attach.Title = fmt.Sprintf("%s, %s (%s)",
@@ -132,39 +129,28 @@ func (plugin *WeatherPlugin) handleWeather() (slack.Response, error) {
 		humField.Short = true
 		attach.AddField(humField)
 		response.AddAttachment(attach)
+		addWeatherCard(&response, attach)
 	}
 
 	return response, nil
 }
 
 // handleForecast handles the request for a forecast
-func (plugin *WeatherPlugin) handleForecast() (slack.Response, error) {
-	var city string
-	parts := strings.Split(plugin.Message(), " ")
-	if len(parts) > 1 {
-		city = strings.Replace(plugin.Message(), parts[0], "", 1)
-	} else {
-		city = plugin.config.determineDefaultWeatherCity(plugin.request)
-	}
-	city = url.QueryEscape(city)
+func (plugin *WeatherPlugin) handleForecast(ctx context.Context, args Args) (slack.Response, error) {
+	city := url.QueryEscape(plugin.weatherCity(args))
 	response := slack.Response{}
 	url := fmt.Sprintf("%sforecast/daily?APPID=%s&q=%s&units=%s",
 		plugin.Source,
 		plugin.config.APIToken,
 		city,
 		plugin.config.Units)
-	resp, err := http.Get(url)
-	if err != nil {
-		return response, err
-	}
-	defer resp.Body.Close()
 	parsedResult := forecastResponse{}
-
-	if err := json.NewDecoder(resp.Body).Decode(&parsedResult); err != nil {
+	if err := getJSON(ctx, plugin.upstream, url, &parsedResult); err != nil {
 		return response, err
 	}
 	commandDetails := getCommandDetails(plugin, "forecast")
 	response.Text = commandDetails.Texts["response_text"]
+	response.AddBlock(slack.NewSectionBlock(slack.Markdown(response.Text)))
 	for _, record := range parsedResult.List {
 		attach := slack.Attachment{}
 		attach.Title = fmt.Sprintf("%s, %s (%s)",
@@ -194,11 +180,27 @@ func (plugin *WeatherPlugin) handleForecast() (slack.Response, error) {
 		humField.Short = true
 		attach.AddField(humField)
 		response.AddAttachment(attach)
+		addWeatherCard(&response, attach)
 	}
 
 	return response, nil
 }
 
+// addWeatherCard adds the Block Kit version of a weather attachment to the
+// response. The card shows the location with the weather icon, followed by
+// the details as fields.
+func addWeatherCard(response *slack.Response, attach slack.Attachment) {
+	response.AddBlock(slack.NewDividerBlock())
+	header := slack.NewSectionBlock(slack.Markdown("*" + attach.Title + "*\n" + attach.Text))
+	header.Accessory = slack.NewImageElement(attach.ThumbURL, attach.Text)
+	response.AddBlock(header)
+	details := slack.NewSectionBlock(nil)
+	for _, field := range attach.Fields {
+		details.AddField(slack.Markdown("*" + field.Title + "*\n" + field.Value))
+	}
+	response.AddBlock(details)
+}
+
 // determineDefaultWeatherCity checks if there are defaults for specific channels
 // and returns those.
 func (config weatherConfig) determineDefaultWeatherCity(request slack.Request) string {
@@ -219,24 +221,14 @@ func (plugin WeatherPlugin) Description(language string) string {
 	return fmt.Sprintf(descrString, plugin.config.determineDefaultWeatherCity(plugin.request))
 }
 
-// Name returns the name of the plugin
-func (plugin WeatherPlugin) Name() string {
-	return plugin.name
-}
-
-// Message returns the request sent
-func (plugin WeatherPlugin) Message() string {
-	return strings.ToLower(plugin.request.Text)
-}
-
 // parseWeatherConfig collects the config as defined in the config file for
-// the weather plugin
-func parseWeatherConfig() (weatherConfig, error) {
+// the weather plugin, with the settings of the team layered over it
+func parseWeatherConfig(teamID string) (weatherConfig, error) {
 	pluginConfig := struct {
 		Weather weatherConfig
 	}{}
 
-	err := config.ParseConfig(&pluginConfig)
+	err := config.ParseTeamConfig(teamID, &pluginConfig)
 	if err != nil {
 		return pluginConfig.Weather, err
 	}
@@ -245,8 +237,6 @@ func parseWeatherConfig() (weatherConfig, error) {
 		return pluginConfig.Weather, err
 	}
 
-	pluginConfig.Weather.languages = getPluginLanguages("weather")
-
 	if pluginConfig.Weather.Units == "" {
 		pluginConfig.Weather.Units = "metric"
 	}
@@ -388,11 +378,9 @@ type (
 	}
 
 	weatherConfig struct {
-		DefaultCity    string
-		APIToken       string
-		Units          string
-		ChannelCity    map[string]string
-		languages      map[string]config.LanguagePluginDetails
-		chosenLanguage string
+		DefaultCity string
+		APIToken    string
+		Units       string
+		ChannelCity map[string]string
 	}
 )
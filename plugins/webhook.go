@@ -0,0 +1,253 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+// maxWebhookResponse is the largest response read from a webhook
+const maxWebhookResponse = 1 << 20
+
+// WebhookPlugin calls an HTTP service configured in the webhooks section of
+// the configuration, and turns its reply into a response
+type WebhookPlugin struct {
+	BasePlugin
+	config  webhookConfig
+	headers map[string]string
+	timeout time.Duration
+}
+
+// webhookConfig contains the settings of a webhook plugin. The triggers are
+// command templates by name, like the commands in the language files.
+type webhookConfig struct {
+	Description string
+	Triggers    map[string]config.LanguagePluginCommandDetails
+	Method      string
+	URL         string
+	Headers     map[string]string
+	Timeout     string
+	Response    webhookMapping
+	// ShareResponseURL includes the response_url in the request sent to
+	// the service
+	ShareResponseURL bool
+}
+
+// webhookMapping contains the fields of the reply used for the response.
+// Fields are found by their path, like "data.title" or "items.0.url".
+type webhookMapping struct {
+	Text  string
+	Title string
+	Link  string
+	Image string
+}
+
+func init() {
+	registerSource("webhooks", webhookRegistrations)
+}
+
+// webhookRegistrations creates the registrations for the webhook plugins in
+// the configuration
+func webhookRegistrations() ([]Registration, error) {
+	pluginConfig := struct {
+		Webhooks map[string]webhookConfig
+	}{}
+	if err := config.ParseConfig(&pluginConfig); err != nil {
+		return nil, err
+	}
+	registrations := []Registration{}
+	for name, details := range pluginConfig.Webhooks {
+		name, details := name, details
+		registrations = append(registrations, Registration{
+			Name:    name,
+			Section: "webhooks",
+			Factory: func(request slack.Request) (IgorPlugin, error) {
+				return newWebhookPlugin(name, details, request)
+			},
+		})
+	}
+	return registrations, nil
+}
+
+// newWebhookPlugin instantiates a WebhookPlugin. Its commands are available
+// in the default language. The header values can be encrypted with KMS.
+func newWebhookPlugin(name string, details webhookConfig, request slack.Request) (IgorPlugin, error) {
+	if details.URL == "" {
+		return WebhookPlugin{}, errors.New("no url configured")
+	}
+	if len(details.Triggers) == 0 {
+		return WebhookPlugin{}, errors.New("no triggers configured")
+	}
+	details.Method = strings.ToUpper(details.Method)
+	if details.Method == "" {
+		details.Method = "GET"
+	}
+	plugin := WebhookPlugin{
+		BasePlugin: BasePlugin{name: name, request: request},
+		config:     details,
+		headers:    make(map[string]string),
+	}
+	for header, value := range details.Headers {
+		decrypted, err := config.DecryptString(value)
+		if err != nil {
+			return WebhookPlugin{}, fmt.Errorf("decrypting header %s: %s", header, err)
+		}
+		plugin.headers[header] = decrypted
+	}
+	if details.Timeout != "" {
+		timeout, err := time.ParseDuration(details.Timeout)
+		if err != nil {
+			return WebhookPlugin{}, err
+		}
+		plugin.timeout = timeout
+	}
+	plugin.languages = triggerLanguages(request, details.Description, details.Triggers)
+	return plugin, nil
+}
+
+// Work calls the service if one of the triggers matches. Requests other
+// than GET and DELETE send the request and its arguments as JSON. An empty
+// reply means the service can't handle the request after all.
+func (plugin WebhookPlugin) Work(ctx context.Context) (slack.Response, error) {
+	response := slack.Response{}
+	command, err := getCommand(plugin, triggerParams(plugin.config.Triggers))
+	if err != nil {
+		return response, err
+	}
+	plugin.chosenLanguage = command.Language
+	input := newTriggerInput(plugin.request, command, plugin.config.ShareResponseURL)
+	var body io.Reader
+	if plugin.config.Method != "GET" && plugin.config.Method != "DELETE" {
+		encoded, err := json.Marshal(input)
+		if err != nil {
+			return response, err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(plugin.config.Method, expandWebhookURL(plugin.config.URL, input), body)
+	if err != nil {
+		return response, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for header, value := range plugin.headers {
+		req.Header.Set(header, value)
+	}
+	if plugin.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, plugin.timeout)
+		defer cancel()
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return response, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return response, CreateNoMatchError("No content")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return response, fmt.Errorf("calling webhook %s: %s", plugin.name, resp.Status)
+	}
+	reply, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxWebhookResponse))
+	if err != nil {
+		return response, err
+	}
+	if plugin.config.Response == (webhookMapping{}) {
+		response, err = decodeResponse(reply)
+	} else {
+		response, err = plugin.config.Response.apply(reply)
+	}
+	if err != nil {
+		return response, fmt.Errorf("parsing the reply of webhook %s: %s", plugin.name, err)
+	}
+	if response.Text == "" && len(response.Attachments) == 0 {
+		return response, CreateNoMatchError("Nothing found")
+	}
+	return response, nil
+}
+
+// expandWebhookURL replaces the placeholders in the URL, like {city}, with
+// the escaped values of the arguments. The user_id, user_name, channel_id,
+// channel_name, and team_id placeholders are filled in from the request.
+func expandWebhookURL(template string, input triggerInput) string {
+	values := map[string]string{
+		"user_id":      input.Request.UserID,
+		"user_name":    input.Request.UserName,
+		"channel_id":   input.Request.ChannelID,
+		"channel_name": input.Request.ChannelName,
+		"team_id":      input.Request.TeamID,
+	}
+	for name, value := range input.Arguments {
+		values[name] = value
+	}
+	replacements := []string{}
+	for name, value := range values {
+		// Escaped so it's safe in both the path and the query
+		escaped := strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+		replacements = append(replacements, "{"+name+"}", escaped)
+	}
+	return strings.NewReplacer(replacements...).Replace(template)
+}
+
+// apply creates the response from the mapped fields of the reply. The title,
+// link, and image are shown as an attachment.
+func (mapping webhookMapping) apply(reply []byte) (slack.Response, error) {
+	response := slack.Response{}
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(reply))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return response, err
+	}
+	response.Text = replyField(data, mapping.Text)
+	attachment := slack.Attachment{
+		Title:     replyField(data, mapping.Title),
+		TitleLink: replyField(data, mapping.Link),
+		ImageURL:  replyField(data, mapping.Image),
+	}
+	if attachment.Title != "" || attachment.ImageURL != "" {
+		response.AddAttachment(attachment)
+	}
+	return response, nil
+}
+
+// replyField finds the value at the path in the decoded reply
+func replyField(data interface{}, path string) string {
+	if path == "" {
+		return ""
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch value := data.(type) {
+		case map[string]interface{}:
+			data = value[key]
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(value) {
+				return ""
+			}
+			data = value[index]
+		default:
+			return ""
+		}
+	}
+	switch value := data.(type) {
+	case nil, map[string]interface{}, []interface{}:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,132 @@
+package plugins_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/plugins"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestWebhook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/status/web server":
+			w.Write([]byte(`{"text": "web server for ` + r.URL.Query().Get("user") + ` is up", "response_type": "in_channel"}`))
+		case "/status/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/deploy":
+			input := struct {
+				Request   slack.Request
+				Command   string
+				Arguments map[string]string
+			}{}
+			json.NewDecoder(r.Body).Decode(&input)
+			if input.Request.ResponseURL != "" {
+				t.Errorf("Expected the response_url not to be shared, actual %q", input.Request.ResponseURL)
+			}
+			w.Write([]byte(`{"deployment": {"service": "` + input.Arguments["service"] + `", "url": "https://example.com/1"}, "images": ["https://example.com/1.png"]}`))
+		default:
+			http.Error(w, "Broken", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	webhookConfig := map[string]interface{}{
+		"token":       "testtoken",
+		"languagedir": "../language",
+		"webhooks": map[string]interface{}{
+			"servicestatus": map[string]interface{}{
+				"description": "Shows the status of our services",
+				"triggers": map[string]interface{}{
+					"servicestatus": map[string]string{"command": "service [name]", "description": "Shows the status"},
+				},
+				"url":     server.URL + "/status/{name}?user={user_name}",
+				"headers": map[string]string{"Authorization": "Bearer secret"},
+			},
+			"deploy": map[string]interface{}{
+				"triggers": map[string]interface{}{
+					"deploy": map[string]string{"command": "deploy [service]"},
+				},
+				"method":   "post",
+				"url":      server.URL + "/deploy",
+				"headers":  map[string]string{"Authorization": "Bearer secret"},
+				"response": map[string]string{"title": "deployment.service", "link": "deployment.url", "image": "images.0"},
+			},
+			"broken": map[string]interface{}{"triggers": map[string]interface{}{}},
+		},
+	}
+	encoded, _ := json.Marshal(webhookConfig)
+	if err := os.Setenv("IGOR_CONFIG", string(encoded)); err != nil {
+		t.Error("Problem setting environment variable")
+	}
+	generalConfig, err := config.GeneralConfig()
+	if err != nil {
+		t.Fatal("Problem getting config")
+	}
+	disabled := make(map[string]bool)
+	for _, diagnostic := range plugins.Diagnostics(generalConfig) {
+		disabled[diagnostic.Plugin] = true
+	}
+	if !disabled["broken"] || disabled["servicestatus"] || disabled["deploy"] {
+		t.Errorf("Expected only the broken webhook to be disabled, actual %v", disabled)
+	}
+
+	request := slack.Request{UserName: "test user", Text: "service web server"}
+	plugin, ok := plugins.GetPlugins(request, generalConfig)["servicestatus"]
+	if !ok {
+		t.Fatal("Expected the servicestatus plugin to be activated")
+	}
+	if plugin.Description("") != "Shows the status of our services" || plugin.Describe("")["service [name]"] != "Shows the status" {
+		t.Error("Expected the description and triggers from the configuration")
+	}
+	response, err := plugin.Work(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error", err.Error())
+	}
+	if response.Text != "web server for test user is up" || response.ResponseType != "in_channel" {
+		t.Errorf("Unexpected response %v", response)
+	}
+
+	request.Text = "deploy api"
+	request.ResponseURL = "https://hooks.slack.com/commands/1"
+	response, err = plugins.GetPlugins(request, generalConfig)["deploy"].Work(context.Background())
+	if err != nil {
+		t.Fatal("Unexpected error", err.Error())
+	}
+	if len(response.Attachments) != 1 {
+		t.Fatalf("Expected an attachment, actual %v", response)
+	}
+	attachment := response.Attachments[0]
+	if attachment.Title != "api" || attachment.TitleLink != "https://example.com/1" || attachment.ImageURL != "https://example.com/1.png" {
+		t.Errorf("Unexpected attachment %v", attachment)
+	}
+
+	var errorTests = []struct {
+		text    string
+		noMatch bool
+	}{
+		{"service empty", true},
+		{"service broken", false},
+		{"status", true},
+	}
+	for _, tt := range errorTests {
+		request.Text = tt.text
+		_, err := plugins.GetPlugins(request, generalConfig)["servicestatus"].Work(context.Background())
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.text)
+			continue
+		}
+		if _, ok := err.(*plugins.NoMatchError); ok != tt.noMatch {
+			t.Errorf("%s: unexpected error %v", tt.text, err)
+		}
+	}
+}
@@ -1,56 +1,45 @@
 package plugins
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
-	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
-	"github.com/ArjenSchwarz/igor/config"
 	"github.com/ArjenSchwarz/igor/slack"
 )
 
 // XkcdPlugin provides access to XKCD comics
 type XkcdPlugin struct {
-	name        string
-	description string
-	request     slack.Request
-	config      xkcdConfig
+	BasePlugin
+	upstream Upstream
 }
 
-// Config returns the plugin configuration
-func (plugin XkcdPlugin) Config() IgorConfig {
-	return plugin.config
-}
-
-type xkcdConfig struct {
-	languages      map[string]config.LanguagePluginDetails
-	chosenLanguage string
-}
-
-func (config xkcdConfig) Languages() map[string]config.LanguagePluginDetails {
-	return config.languages
-}
-
-func (config xkcdConfig) ChosenLanguage() string {
-	return config.chosenLanguage
+func init() {
+	Register(Registration{
+		Name:    "xkcd",
+		Factory: Xkcd,
+		Actions: map[string]string{
+			"previous": "xkcd_specific",
+			"next":     "xkcd_specific",
+			"random":   "xkcd_random",
+		},
+	})
+	// The latest comic only changes a few times a week, and the others
+	// never do
+	registerUpstream("xkcd", Upstream{URL: "https://xkcd.com/", Retries: DefaultUpstreamRetries, Cache: 10 * time.Minute})
 }
 
 // Xkcd is a plugin that returns XKCD comics
 func Xkcd(request slack.Request) (IgorPlugin, error) {
-	pluginName := "xkcd"
-	pluginConfig := xkcdConfig{
-		languages: getPluginLanguages(pluginName),
-	}
-	plugin := XkcdPlugin{
-		name:    pluginName,
-		request: request,
-		config:  pluginConfig,
+	upstreams, err := teamUpstreams(request.TeamID, "xkcd")
+	if err != nil {
+		return XkcdPlugin{}, err
 	}
-	return plugin, nil
+	return XkcdPlugin{BasePlugin: NewBasePlugin("xkcd", request), upstream: upstreams["xkcd"]}, nil
 }
 
 type xkcdEntry struct {
@@ -62,58 +51,92 @@ type xkcdEntry struct {
 
 // Work parses the request and ensures a request comes through if any triggers
 // are matched.
-func (plugin XkcdPlugin) Work() (slack.Response, error) {
+func (plugin XkcdPlugin) Work(ctx context.Context) (slack.Response, error) {
 	response := slack.Response{}
-	message, language := getCommandName(plugin)
-	if message == "" {
-		return response, CreateNoMatchError("Nothing found")
+	command, err := getCommand(plugin, xkcdParams)
+	if err != nil {
+		return response, err
 	}
-	plugin.config.chosenLanguage = language
+	plugin.chosenLanguage = command.Language
 	response.SetPublic()
-	baseurl := "http://xkcd.com/"
-	jsoncall := "info.0.json"
-	switch message {
+	switch command.Name {
 	case "xkcd":
-		url := fmt.Sprintf("%s%s", baseurl, jsoncall)
-		return plugin.parseXkcdMessage(url, response)
+		return plugin.parseXkcdMessage(ctx, xkcdURL(""), response)
 	case "xkcd_random":
-		url := fmt.Sprintf("%s%s", baseurl, jsoncall)
-		entry, err := getXkcdMessage(url)
+		url, err := randomXkcdURL(ctx, plugin.upstream)
 		if err != nil {
 			return response, err
 		}
-		rand.Seed(time.Now().UTC().UnixNano())
-		comicnr := rand.Intn(entry.Number)
-		url = fmt.Sprintf("%s%v/%s", baseurl, comicnr, jsoncall)
-		return plugin.parseXkcdMessage(url, response)
+		return plugin.parseXkcdMessage(ctx, url, response)
 	case "xkcd_specific":
-		parts := strings.Split(plugin.Message(), " ")
-		comicnr := ""
-		if len(parts) > 1 {
-			comicnr = strings.TrimSpace(strings.Replace(plugin.Message(), parts[0], "", 1))
-		}
-		url := fmt.Sprintf("%s%v/%s", baseurl, comicnr, jsoncall)
-		return plugin.parseXkcdMessage(url, response)
+		return plugin.parseXkcdMessage(ctx, xkcdURL(command.Args.String("nr")), response)
 	}
 	return response, CreateNoMatchError("Nothing found")
 }
 
-func getXkcdMessage(url string) (xkcdEntry, error) {
+// xkcdParams contains the parameters of the commands
+var xkcdParams = map[string][]Param{
+	"xkcd_specific": {{Name: "nr", Type: IntParam}},
+}
+
+// HandleAction handles the navigation buttons shown below a comic. Handled
+// actions:
+//
+// * previous
+// * next
+// * random
+func (plugin XkcdPlugin) HandleAction(ctx context.Context, action slack.Action) (slack.Response, error) {
+	response := slack.Response{ReplaceOriginal: true}
+	response.SetPublic()
+	switch action.Name {
+	case "previous", "next":
+		return plugin.parseXkcdMessage(ctx, xkcdURL(action.Value), response)
+	case "random":
+		url, err := randomXkcdURL(ctx, plugin.upstream)
+		if err != nil {
+			return response, err
+		}
+		return plugin.parseXkcdMessage(ctx, url, response)
+	}
+	return response, CreateNoMatchError("Unknown action")
+}
+
+// xkcdURL returns the path for the comic on the xkcd upstream. An empty
+// comic number returns the path for the latest comic.
+func xkcdURL(comicnr string) string {
+	jsoncall := "info.0.json"
+	if comicnr == "" {
+		return jsoncall
+	}
+	return fmt.Sprintf("%v/%s", comicnr, jsoncall)
+}
+
+// randomXkcdURL returns the URL for a random comic
+func randomXkcdURL(ctx context.Context, upstream Upstream) (string, error) {
+	entry, err := getXkcdMessage(ctx, upstream, xkcdURL(""))
+	if err != nil {
+		return "", err
+	}
+	rand.Seed(time.Now().UTC().UnixNano())
+	comicnr := rand.Intn(entry.Number) + 1
+	return xkcdURL(strconv.Itoa(comicnr)), nil
+}
+
+func getXkcdMessage(ctx context.Context, upstream Upstream, url string) (xkcdEntry, error) {
 	parsedResult := xkcdEntry{}
-	resp, err := http.Get(url)
-	defer resp.Body.Close()
+	resp, err := fetch(ctx, upstream, url)
 	if err != nil {
 		return parsedResult, err
 	}
 	if resp.StatusCode == 404 {
 		return parsedResult, errors.New("Incorrect comic number")
 	}
-	err = json.NewDecoder(resp.Body).Decode(&parsedResult)
+	err = json.Unmarshal(resp.Body, &parsedResult)
 	return parsedResult, err
 }
 
-func (plugin XkcdPlugin) parseXkcdMessage(url string, response slack.Response) (slack.Response, error) {
-	parsedResult, err := getXkcdMessage(url)
+func (plugin XkcdPlugin) parseXkcdMessage(ctx context.Context, url string, response slack.Response) (slack.Response, error) {
+	parsedResult, err := getXkcdMessage(ctx, plugin.upstream, url)
 	if err != nil {
 		return response, err
 	}
@@ -124,31 +147,22 @@ func (plugin XkcdPlugin) parseXkcdMessage(url string, response slack.Response) (
 		Text:     parsedResult.Alt,
 		Title:    parsedResult.Title,
 	}
+	if parsedResult.Number > 1 {
+		attach.AddAction(plugin.name, slack.NewButton("previous", commandDetails.Texts["previous"], strconv.Itoa(parsedResult.Number-1)))
+	}
+	attach.AddAction(plugin.name, slack.NewButton("random", commandDetails.Texts["random"], ""))
+	if url != xkcdURL("") && plugin.hasNewer(ctx, parsedResult.Number) {
+		attach.AddAction(plugin.name, slack.NewButton("next", commandDetails.Texts["next"], strconv.Itoa(parsedResult.Number+1)))
+	}
 	response.AddAttachment(attach)
 	return response, nil
 }
 
-// Describe provides the triggers the plugin can handle
-func (plugin XkcdPlugin) Describe(language string) map[string]string {
-	descriptions := make(map[string]string)
-
-	for _, values := range getAllCommands(plugin, language) {
-		descriptions[values.Command] = values.Description
-	}
-	return descriptions
-}
-
-// Name returns the name of the plugin
-func (plugin XkcdPlugin) Name() string {
-	return plugin.name
-}
-
-// Description returns a global description of the plugin
-func (plugin XkcdPlugin) Description(language string) string {
-	return getDescriptionText(plugin, language)
-}
-
-// Message returns the original request message
-func (plugin XkcdPlugin) Message() string {
-	return strings.ToLower(plugin.request.Text)
+// hasNewer checks if there's a comic after the one with the number, so the
+// latest comic doesn't get a button to a comic that doesn't exist. The
+// latest comic is cached, so this rarely needs another request. If it can't
+// be retrieved, the comic is assumed to have a newer one.
+func (plugin XkcdPlugin) hasNewer(ctx context.Context, number int) bool {
+	latest, err := getXkcdMessage(ctx, plugin.upstream, xkcdURL(""))
+	return err != nil || latest.Number > number
 }
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/logging"
+	"github.com/ArjenSchwarz/igor/metrics"
+	"github.com/ArjenSchwarz/igor/platforms"
+)
+
+// The defaults for the server settings that aren't configured
+const (
+	defaultAddress         = ":8080"
+	defaultReadTimeout     = 10 * time.Second
+	defaultWriteTimeout    = 30 * time.Second
+	defaultIdleTimeout     = 60 * time.Second
+	defaultShutdownTimeout = 30 * time.Second
+	defaultMaxBodySize     = 1 << 20
+)
+
+// draining is set once the server is shutting down, so it's no longer
+// reported as ready
+var draining int32
+
+// serve runs Igor as an HTTP server until it receives SIGTERM or SIGINT.
+// It then stops accepting requests, and waits for the requests and
+// background jobs in progress until the shutdown timeout passes.
+func serve(serverConfig config.ServerConfig) error {
+	shutdownTimeout, err := serverDuration(serverConfig.ShutdownTimeout, defaultShutdownTimeout)
+	if err != nil {
+		return err
+	}
+	server, err := newServer(serverConfig)
+	if err != nil {
+		return err
+	}
+	errs := make(chan error, 1)
+	go func() {
+		logging.New().Infof("Listening on %s", server.Addr)
+		if serverConfig.TLSCert != "" || serverConfig.TLSKey != "" {
+			errs <- server.ListenAndServeTLS(serverConfig.TLSCert, serverConfig.TLSKey)
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	select {
+	case err := <-errs:
+		return err
+	case received := <-stop:
+		logging.New().Infof("Received %s, shutting down", received)
+	}
+	atomic.StoreInt32(&draining, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		return err
+	}
+	finished := make(chan struct{})
+	go func() {
+		waitForBackgroundJobs()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		return fmt.Errorf("background jobs still running after %s", shutdownTimeout)
+	}
+	return nil
+}
+
+// newServer creates the server with the configured address and timeouts.
+// Next to the commands it serves the health checks and the metrics.
+func newServer(serverConfig config.ServerConfig) (*http.Server, error) {
+	server := &http.Server{Addr: serverConfig.Address}
+	if server.Addr == "" {
+		server.Addr = defaultAddress
+	}
+	var err error
+	if server.ReadTimeout, err = serverDuration(serverConfig.ReadTimeout, defaultReadTimeout); err != nil {
+		return nil, err
+	}
+	if server.WriteTimeout, err = serverDuration(serverConfig.WriteTimeout, defaultWriteTimeout); err != nil {
+		return nil, err
+	}
+	if server.IdleTimeout, err = serverDuration(serverConfig.IdleTimeout, defaultIdleTimeout); err != nil {
+		return nil, err
+	}
+	maxBodySize := serverConfig.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultMaxBodySize
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handleHealth)
+	mux.HandleFunc("/readyz", handleReady)
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/", commandHandler(maxBodySize))
+	server.Handler = mux
+	return server, nil
+}
+
+// serverDuration parses a configured duration, using the fallback if it
+// isn't set
+func serverDuration(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid server timeout %q: %s", value, err)
+	}
+	return duration, nil
+}
+
+// commandHandler handles the slash commands and the other endpoints. Only
+// POST requests are accepted, with a body of at most maxBodySize bytes.
+func commandHandler(maxBodySize int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler := handleCommand
+		if r.URL.Path != "/" {
+			var ok bool
+			if handler, ok = endpoints[strings.Trim(r.URL.Path, "/")]; !ok {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// The raw body is required to verify the request signature
+		requestBody, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if int64(len(requestBody)) > maxBodySize {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		headers := make(map[string]string)
+		for name := range r.Header {
+			headers[name] = r.Header.Get(name)
+		}
+		response := handler(r.Context(), body{Body: string(requestBody), Headers: headers})
+		statusCode := http.StatusOK
+		if reply, ok := response.(platforms.Reply); ok {
+			statusCode = reply.StatusCode
+			response = reply.Body
+		}
+		if response == nil {
+			w.WriteHeader(statusCode)
+			return
+		}
+		responseString, _ := json.Marshal(response)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(statusCode)
+		w.Write(responseString)
+	})
+}
+
+// handleHealth reports that the server is running
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// handleReady reports whether Igor can handle requests, which requires the
+// configuration and the language files to be loaded. Once the server is
+// shutting down it's no longer ready.
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&draining) == 1 {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	config, err := config.GeneralConfig()
+	if err != nil {
+		logging.New().Error("Not ready, the configuration can't be loaded", err)
+		http.Error(w, "configuration not loaded", http.StatusServiceUnavailable)
+		return
+	}
+	if _, ok := config.Languages[config.DefaultLanguage]; !ok {
+		http.Error(w, "default language "+config.DefaultLanguage+" not loaded", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
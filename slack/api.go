@@ -0,0 +1,92 @@
+// Package slack provides all the Slack specific code for Igor
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// APIURL is the base URL of the Slack Web API
+var APIURL = "https://slack.com/api/"
+
+// Message contains the fields for posting a Response through the Web API
+type Message struct {
+	Response
+	Channel  string `json:"channel"`
+	User     string `json:"user,omitempty"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+}
+
+// PostMessage posts a message to a channel using the chat.postMessage method
+func PostMessage(token string, message Message) error {
+	return callAPI("chat.postMessage", token, message)
+}
+
+// PostEphemeral posts a message that is only visible to the message's User
+// using the chat.postEphemeral method
+func PostEphemeral(token string, message Message) error {
+	return callAPI("chat.postEphemeral", token, message)
+}
+
+// UserGroupMembers returns the IDs of the users in the user group using the
+// usergroups.users.list method
+func UserGroupMembers(token string, group string) ([]string, error) {
+	form := url.Values{"usergroup": {group}}
+	req, err := http.NewRequest("POST", APIURL+"usergroups.users.list", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Ok    bool     `json:"ok"`
+		Error string   `json:"error"`
+		Users []string `json:"users"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if !result.Ok {
+		return nil, errors.New("Slack API call usergroups.users.list failed: " + result.Error)
+	}
+	return result.Users, nil
+}
+
+// callAPI calls a Web API method with a JSON payload
+func callAPI(method string, token string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", APIURL+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Ok {
+		return errors.New("Slack API call " + method + " failed: " + result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,364 @@
+// Package slack provides all the Slack specific code for Igor
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Block is the interface for all Block Kit blocks. Blocks are created through
+// their New functions, which ensure the type is set correctly.
+type Block interface {
+	blockType() string
+}
+
+// Blocks is a list of Block Kit blocks
+type Blocks []Block
+
+// TextObject contains the fields for a text composition object. Within a
+// context block it can also be an image, using ImageURL and AltText.
+type TextObject struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	AltText  string `json:"alt_text,omitempty"`
+}
+
+// ConfirmObject contains the fields for a confirmation dialog shown before
+// an element's action is sent
+type ConfirmObject struct {
+	Title   *TextObject `json:"title"`
+	Text    *TextObject `json:"text"`
+	Confirm *TextObject `json:"confirm"`
+	Deny    *TextObject `json:"deny"`
+}
+
+// Element contains the fields for the interactive and image elements that
+// can be used as an accessory or in an actions block
+type Element struct {
+	Type     string         `json:"type"`
+	Text     *TextObject    `json:"text,omitempty"`
+	ActionID string         `json:"action_id,omitempty"`
+	Value    string         `json:"value,omitempty"`
+	Style    string         `json:"style,omitempty"`
+	URL      string         `json:"url,omitempty"`
+	ImageURL string         `json:"image_url,omitempty"`
+	AltText  string         `json:"alt_text,omitempty"`
+	Confirm  *ConfirmObject `json:"confirm,omitempty"`
+}
+
+// SectionBlock contains the fields for a section block
+type SectionBlock struct {
+	Type      string        `json:"type"`
+	BlockID   string        `json:"block_id,omitempty"`
+	Text      *TextObject   `json:"text,omitempty"`
+	Fields    []*TextObject `json:"fields,omitempty"`
+	Accessory *Element      `json:"accessory,omitempty"`
+}
+
+// ContextBlock contains the fields for a context block
+type ContextBlock struct {
+	Type     string        `json:"type"`
+	BlockID  string        `json:"block_id,omitempty"`
+	Elements []*TextObject `json:"elements"`
+}
+
+// ImageBlock contains the fields for an image block
+type ImageBlock struct {
+	Type     string      `json:"type"`
+	BlockID  string      `json:"block_id,omitempty"`
+	ImageURL string      `json:"image_url"`
+	AltText  string      `json:"alt_text"`
+	Title    *TextObject `json:"title,omitempty"`
+}
+
+// DividerBlock contains the fields for a divider block
+type DividerBlock struct {
+	Type    string `json:"type"`
+	BlockID string `json:"block_id,omitempty"`
+}
+
+// ActionsBlock contains the fields for an actions block. The BlockID is the
+// name of the plugin that handles the actions, which can be followed by a
+// number to keep it unique within the message.
+type ActionsBlock struct {
+	Type     string     `json:"type"`
+	BlockID  string     `json:"block_id,omitempty"`
+	Elements []*Element `json:"elements"`
+}
+
+func (SectionBlock) blockType() string { return "section" }
+func (ContextBlock) blockType() string { return "context" }
+func (ImageBlock) blockType() string   { return "image" }
+func (DividerBlock) blockType() string { return "divider" }
+func (ActionsBlock) blockType() string { return "actions" }
+
+// MaxSectionFields is the maximum number of fields Slack allows in a section
+const MaxSectionFields = 10
+
+// Markdown creates a mrkdwn text object
+func Markdown(text string) *TextObject {
+	return &TextObject{Type: "mrkdwn", Text: text}
+}
+
+// PlainText creates a plain_text text object
+func PlainText(text string) *TextObject {
+	return &TextObject{Type: "plain_text", Text: text}
+}
+
+// ContextImage creates an image that can be used within a context block
+func ContextImage(imageURL string, altText string) *TextObject {
+	return &TextObject{Type: "image", ImageURL: imageURL, AltText: altText}
+}
+
+// NewSectionBlock creates a section block with the provided text
+func NewSectionBlock(text *TextObject) *SectionBlock {
+	return &SectionBlock{Type: "section", Text: text}
+}
+
+// AddField adds a field to the section
+func (block *SectionBlock) AddField(field *TextObject) {
+	block.Fields = append(block.Fields, field)
+}
+
+// NewContextBlock creates a context block containing the elements
+func NewContextBlock(elements ...*TextObject) *ContextBlock {
+	return &ContextBlock{Type: "context", Elements: elements}
+}
+
+// NewImageBlock creates an image block. The title is optional.
+func NewImageBlock(imageURL string, altText string, title string) *ImageBlock {
+	block := &ImageBlock{Type: "image", ImageURL: imageURL, AltText: altText}
+	if title != "" {
+		block.Title = PlainText(title)
+	}
+	return block
+}
+
+// NewDividerBlock creates a divider block
+func NewDividerBlock() *DividerBlock {
+	return &DividerBlock{Type: "divider"}
+}
+
+// NewActionsBlock creates an actions block. The callback is the name of the
+// plugin that handles the actions.
+func NewActionsBlock(callback string, elements ...*Element) *ActionsBlock {
+	return &ActionsBlock{Type: "actions", BlockID: callback, Elements: elements}
+}
+
+// NewImageElement creates an image element, for use as a section accessory
+func NewImageElement(imageURL string, altText string) *Element {
+	return &Element{Type: "image", ImageURL: imageURL, AltText: altText}
+}
+
+// NewButtonElement creates a button element. When clicked, the action and
+// value are sent back to Igor.
+func NewButtonElement(action string, text string, value string) *Element {
+	return &Element{Type: "button", ActionID: action, Text: PlainText(text), Value: value}
+}
+
+// AddBlock adds a block to the response
+func (response *Response) AddBlock(block Block) {
+	response.Blocks = append(response.Blocks, block)
+}
+
+// UnmarshalJSON parses a list of blocks into their specific types, so
+// responses from external sources can contain blocks as well
+func (blocks *Blocks) UnmarshalJSON(data []byte) error {
+	var rawBlocks []json.RawMessage
+	if err := json.Unmarshal(data, &rawBlocks); err != nil {
+		return err
+	}
+	result := Blocks{}
+	for _, rawBlock := range rawBlocks {
+		var typed struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(rawBlock, &typed); err != nil {
+			return err
+		}
+		var block Block
+		switch typed.Type {
+		case "section":
+			block = &SectionBlock{}
+		case "context":
+			block = &ContextBlock{}
+		case "image":
+			block = &ImageBlock{}
+		case "divider":
+			block = &DividerBlock{}
+		case "actions":
+			block = &ActionsBlock{}
+		default:
+			return errors.New("Unsupported block type: " + typed.Type)
+		}
+		if err := json.Unmarshal(rawBlock, block); err != nil {
+			return err
+		}
+		result = append(result, block)
+	}
+	*blocks = result
+	return nil
+}
+
+// escape escapes the text of the text object
+func (text *TextObject) escape() {
+	if text != nil {
+		text.Text = EscapeString(text.Text)
+	}
+}
+
+// escapeBlocks escapes all text within the blocks
+func escapeBlocks(blocks Blocks) {
+	for _, block := range blocks {
+		switch typed := block.(type) {
+		case *SectionBlock:
+			typed.Text.escape()
+			for _, field := range typed.Fields {
+				field.escape()
+			}
+			if typed.Accessory != nil {
+				typed.Accessory.Text.escape()
+			}
+		case *ContextBlock:
+			for _, element := range typed.Elements {
+				element.escape()
+			}
+		case *ImageBlock:
+			typed.Title.escape()
+		case *ActionsBlock:
+			for _, element := range typed.Elements {
+				element.Text.escape()
+			}
+		}
+	}
+}
+
+// ToBlocks converts an attachment into blocks, so plugins that only use
+// attachments can be shown using Block Kit
+func (a Attachment) ToBlocks() Blocks {
+	blocks := Blocks{}
+	if a.PreText != "" {
+		blocks = append(blocks, NewSectionBlock(Markdown(a.PreText)))
+	}
+	if a.AuthorName != "" {
+		author := a.AuthorName
+		if a.AuthorLink != "" {
+			author = "<" + a.AuthorLink + "|" + a.AuthorName + ">"
+		}
+		context := NewContextBlock()
+		if a.AuthorIcon != "" {
+			context.Elements = append(context.Elements, ContextImage(a.AuthorIcon, a.AuthorName))
+		}
+		context.Elements = append(context.Elements, Markdown(author))
+		blocks = append(blocks, context)
+	}
+	text := a.Text
+	if a.Title != "" {
+		title := a.Title
+		if a.TitleLink != "" {
+			title = "<" + a.TitleLink + "|" + a.Title + ">"
+		}
+		text = "*" + title + "*\n" + text
+	}
+	if text != "" || len(a.Fields) > 0 {
+		section := NewSectionBlock(nil)
+		if text != "" {
+			section.Text = Markdown(text)
+		}
+		for i, field := range a.Fields {
+			if i > 0 && i%MaxSectionFields == 0 {
+				blocks = append(blocks, section)
+				section = NewSectionBlock(nil)
+			}
+			section.AddField(Markdown("*" + field.Title + "*\n" + field.Value))
+		}
+		if a.ThumbURL != "" {
+			section.Accessory = NewImageElement(a.ThumbURL, a.Title)
+		}
+		blocks = append(blocks, section)
+	}
+	if a.ImageURL != "" {
+		altText := a.Title
+		if altText == "" {
+			altText = a.Fallback
+		}
+		blocks = append(blocks, NewImageBlock(a.ImageURL, altText, ""))
+	}
+	if len(a.Actions) > 0 {
+		actions := NewActionsBlock(a.CallbackID)
+		for _, action := range a.Actions {
+			actions.Elements = append(actions.Elements, action.toElement())
+		}
+		blocks = append(blocks, actions)
+	}
+	return blocks
+}
+
+// toElement converts a legacy action into a button element
+func (action Action) toElement() *Element {
+	element := NewButtonElement(action.Name, action.Text, action.Value)
+	element.Style = action.Style
+	if action.Confirm != nil {
+		element.Confirm = &ConfirmObject{
+			Title:   PlainText(action.Confirm.Title),
+			Text:    Markdown(action.Confirm.Text),
+			Confirm: PlainText(action.Confirm.OkText),
+			Deny:    PlainText(action.Confirm.DismissText),
+		}
+	}
+	return element
+}
+
+// ConvertToBlocks replaces the text and attachments of the response with the
+// equivalent blocks. The text is kept, as Slack uses it for notifications.
+func (response *Response) ConvertToBlocks() {
+	if response.Text != "" {
+		response.AddBlock(NewSectionBlock(Markdown(response.Text)))
+	}
+	for i, attach := range response.Attachments {
+		if i > 0 || response.Text != "" {
+			response.AddBlock(NewDividerBlock())
+		}
+		for _, block := range attach.ToBlocks() {
+			// Block IDs have to be unique within a message, so the
+			// actions of every attachment get its position added
+			if actions, ok := block.(*ActionsBlock); ok && actions.BlockID != "" {
+				actions.BlockID = actions.BlockID + blockIDSeparator + strconv.Itoa(i)
+			}
+			response.AddBlock(block)
+		}
+	}
+	response.Attachments = nil
+}
+
+// blockIDSeparator separates the plugin from the number in the ID of an
+// actions block
+const blockIDSeparator = "/"
+
+// blockCallback returns the plugin that handles the actions of a block, by
+// removing the number added to its ID
+func blockCallback(blockID string) string {
+	position := strings.LastIndex(blockID, blockIDSeparator)
+	if position <= 0 {
+		return blockID
+	}
+	if _, err := strconv.Atoi(blockID[position+1:]); err != nil {
+		return blockID
+	}
+	return blockID[:position]
+}
+
+// PrepareBlocks prepares a response for sending to Slack. When a response
+// has blocks its attachments are left out, as they are only there for
+// platforms that don't support Block Kit. If convert is true, responses
+// without blocks have their attachments converted into blocks.
+func (response *Response) PrepareBlocks(convert bool) {
+	if len(response.Blocks) > 0 {
+		response.Attachments = nil
+	} else if convert && len(response.Attachments) > 0 {
+		response.ConvertToBlocks()
+	}
+}
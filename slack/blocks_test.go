@@ -0,0 +1,124 @@
+package slack_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestConvertToBlocks(t *testing.T) {
+	response := slack.Response{Text: "Your weather request"}
+	attach := slack.Attachment{Title: "Melbourne", Text: "Sunny", ThumbURL: "http://example.com/sun.png", ImageURL: "http://example.com/map.png"}
+	attach.AddField(slack.Field{Title: "Temp", Value: "20 C", Short: true})
+	attach.AddAction("xkcd", slack.NewButton("next", "Next", "2"))
+	response.AddAttachment(attach)
+	second := slack.Attachment{Title: "Sydney"}
+	second.AddAction("xkcd", slack.NewButton("next", "Next", "3"))
+	response.AddAttachment(second)
+	response.ConvertToBlocks()
+
+	if response.Attachments != nil {
+		t.Error("Expected the attachments to be removed")
+	}
+	if response.Text == "" {
+		t.Error("Expected the text to be kept for notifications")
+	}
+	expectedTypes := []string{"section", "divider", "section", "image", "actions", "divider", "section", "actions"}
+	if len(response.Blocks) != len(expectedTypes) {
+		t.Fatalf("Expected %v blocks, actual %v", len(expectedTypes), len(response.Blocks))
+	}
+	section := response.Blocks[2].(*slack.SectionBlock)
+	if section.Text.Text != "*Melbourne*\nSunny" {
+		t.Errorf("Unexpected section text %v", section.Text.Text)
+	}
+	if len(section.Fields) != 1 || section.Accessory == nil {
+		t.Error("Expected the fields and thumbnail in the section")
+	}
+	actions := response.Blocks[4].(*slack.ActionsBlock)
+	if actions.BlockID != "xkcd/0" || actions.Elements[0].ActionID != "next" || actions.Elements[0].Value != "2" {
+		t.Error("Expected the action to keep its callback, name and value")
+	}
+	if second := response.Blocks[7].(*slack.ActionsBlock); second.BlockID != "xkcd/1" {
+		t.Errorf("Expected the block IDs of the attachments to be unique, actual %v", second.BlockID)
+	}
+}
+
+func TestPrepareBlocks(t *testing.T) {
+	response := slack.Response{Text: "test"}
+	response.AddAttachment(slack.Attachment{Text: "attachment"})
+	response.PrepareBlocks(false)
+	if len(response.Attachments) != 1 || len(response.Blocks) != 0 {
+		t.Error("Expected attachments to be kept when not converting")
+	}
+	response.AddBlock(slack.NewSectionBlock(slack.Markdown("block")))
+	response.PrepareBlocks(false)
+	if response.Attachments != nil {
+		t.Error("Expected attachments to be left out when there are blocks")
+	}
+}
+
+func TestEscapeBlocks(t *testing.T) {
+	response := slack.Response{}
+	section := slack.NewSectionBlock(slack.Markdown("a < b"))
+	section.AddField(slack.Markdown("c & d"))
+	response.AddBlock(section)
+	response.AddBlock(slack.NewContextBlock(slack.Markdown("e > f")))
+	response.AddAttachment(slack.Attachment{Title: "g & h", Fields: []slack.Field{{Title: "i", Value: "<j>"}}})
+	response.Escape()
+	if section.Text.Text != "a &lt; b" || section.Fields[0].Text != "c &amp; d" {
+		t.Error("Section text wasn't escaped")
+	}
+	if response.Blocks[1].(*slack.ContextBlock).Elements[0].Text != "e &gt; f" {
+		t.Error("Context text wasn't escaped")
+	}
+	if response.Attachments[0].Title != "g &amp; h" || response.Attachments[0].Fields[0].Value != "&lt;j&gt;" {
+		t.Error("Attachment text wasn't escaped")
+	}
+}
+
+func TestUnmarshalBlocks(t *testing.T) {
+	original := slack.Response{Text: "test"}
+	original.AddBlock(slack.NewSectionBlock(slack.Markdown("section")))
+	original.AddBlock(slack.NewDividerBlock())
+	original.AddBlock(slack.NewImageBlock("http://example.com/image.png", "image", "title"))
+	original.AddBlock(slack.NewActionsBlock("xkcd", slack.NewButtonElement("next", "Next", "2")))
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatal("Unexpected error", err.Error())
+	}
+	parsed := slack.Response{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal("Unexpected error", err.Error())
+	}
+	if len(parsed.Blocks) != 4 {
+		t.Fatalf("Expected 4 blocks, actual %v", len(parsed.Blocks))
+	}
+	if _, ok := parsed.Blocks[3].(*slack.ActionsBlock); !ok {
+		t.Error("Expected the actions block to keep its type")
+	}
+	if err := json.Unmarshal([]byte(`{"blocks":[{"type":"unknown"}]}`), &parsed); err == nil {
+		t.Error("Expected an error for an unknown block type")
+	}
+}
+
+func TestManyFieldsToBlocks(t *testing.T) {
+	attach := slack.Attachment{Title: "Status"}
+	for i := 0; i < 25; i++ {
+		attach.AddField(slack.Field{Title: "Service", Value: "up", Short: true})
+	}
+	fields := 0
+	for _, block := range attach.ToBlocks() {
+		section, ok := block.(*slack.SectionBlock)
+		if !ok {
+			continue
+		}
+		if len(section.Fields) > slack.MaxSectionFields {
+			t.Errorf("Expected at most %d fields in a section, actual %d", slack.MaxSectionFields, len(section.Fields))
+		}
+		fields += len(section.Fields)
+	}
+	if fields != 25 {
+		t.Errorf("Expected every field to be kept, actual %d", fields)
+	}
+}
@@ -0,0 +1,34 @@
+// Package slack provides all the Slack specific code for Igor
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/metrics"
+)
+
+// httpClient is the client used for sending messages to Slack
+var httpClient = &http.Client{Timeout: 10 * time.Second, Transport: metrics.Transport{}}
+
+// PostResponse sends a response to the response_url of a request. This is
+// used for delayed responses, where the work is done after the request was
+// acknowledged.
+func PostResponse(responseURL string, response Response) error {
+	payload, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(responseURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Posting to the response_url failed with status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package slack_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestPostResponse(t *testing.T) {
+	var received slack.Response
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Error("Expected a JSON content type")
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	response := slack.Response{Text: "delayed"}
+	response.SetPublic()
+	if err := slack.PostResponse(server.URL, response); err != nil {
+		t.Error("Unexpected error posting response", err.Error())
+	}
+	if received.Text != "delayed" || !received.IsPublic() {
+		t.Error("The posted response doesn't match the original")
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer failing.Close()
+	if err := slack.PostResponse(failing.URL, response); err == nil {
+		t.Error("Expected an error for a failed post")
+	}
+}
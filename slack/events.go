@@ -0,0 +1,96 @@
+// Package slack provides all the Slack specific code for Igor
+package slack
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/ArjenSchwarz/igor/helpers"
+)
+
+// RetryHeader is the header Slack adds when it retries delivering an event
+const RetryHeader = "X-Slack-Retry-Num"
+
+// EventCallback contains the information sent by the Slack Events API
+type EventCallback struct {
+	Token     string `json:"token"`
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	TeamID    string `json:"team_id"`
+	EventID   string `json:"event_id"`
+	Event     Event  `json:"event"`
+	Retry     bool   `json:"-"`
+	request   Request
+}
+
+// Event contains the details of the event within an EventCallback
+type Event struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	User        string `json:"user"`
+	BotID       string `json:"bot_id"`
+	Text        string `json:"text"`
+	Channel     string `json:"channel"`
+	ChannelType string `json:"channel_type"`
+	TimeStamp   string `json:"ts"`
+	ThreadTS    string `json:"thread_ts"`
+}
+
+// mentionRegex matches the user mentions at the start of a message
+var mentionRegex = regexp.MustCompile(`^(\s*<@[A-Z0-9]+(\|[^>]*)?>)+\s*`)
+
+// LoadEventCallback translates the body and headers of a request sent by the
+// Slack Events API into an EventCallback
+func LoadEventCallback(body string, headers map[string]string) (EventCallback, error) {
+	callback := EventCallback{}
+	if err := json.Unmarshal([]byte(body), &callback); err != nil {
+		return callback, err
+	}
+	callback.Retry = helpers.GetHeader(headers, RetryHeader) != ""
+	callback.request = Request{
+		Token:     callback.Token,
+		TeamID:    callback.TeamID,
+		ChannelID: callback.Event.Channel,
+		UserID:    callback.Event.User,
+		Text:      strings.TrimSpace(mentionRegex.ReplaceAllString(callback.Event.Text, "")),
+		Platform:  "slack",
+		rawBody:   body,
+		signature: helpers.GetHeader(headers, SignatureHeader),
+		timestamp: helpers.GetHeader(headers, TimestampHeader),
+	}
+	return callback, nil
+}
+
+// IsURLVerification returns whether this is the challenge Slack sends when
+// configuring the Events API endpoint
+func (callback EventCallback) IsURLVerification() bool {
+	return callback.Type == "url_verification"
+}
+
+// IsCommand returns whether the event is something Igor should respond to.
+// These are mentions of Igor and direct messages, as long as they don't come
+// from a bot (including Igor itself) and aren't edits or other subtypes.
+func (callback EventCallback) IsCommand() bool {
+	if callback.Type != "event_callback" || callback.Event.BotID != "" || callback.Event.Subtype != "" {
+		return false
+	}
+	switch callback.Event.Type {
+	case "app_mention":
+		return true
+	case "message":
+		return callback.Event.ChannelType == "im"
+	}
+	return false
+}
+
+// IsDirectMessage returns whether the event took place in a direct message
+func (callback EventCallback) IsDirectMessage() bool {
+	return callback.Event.ChannelType == "im"
+}
+
+// Request returns the event as a Request, so it can be handled and validated
+// like a slash command
+func (callback EventCallback) Request() Request {
+	return callback.request
+}
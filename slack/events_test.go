@@ -0,0 +1,53 @@
+package slack_test
+
+import (
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestLoadEventCallback(t *testing.T) {
+	body := `{"token":"testtoken","team_id":"T1","type":"event_callback","event_id":"Ev1",
+		"event":{"type":"app_mention","user":"U1","text":"<@U0IGOR> weather melbourne","channel":"C1","ts":"1.2"}}`
+	callback, err := slack.LoadEventCallback(body, map[string]string{"X-Slack-Retry-Num": "1"})
+	if err != nil {
+		t.Fatal("Unexpected error", err.Error())
+	}
+	if !callback.IsCommand() {
+		t.Error("Expected a mention to be a command")
+	}
+	if !callback.Retry {
+		t.Error("Expected the event to be marked as a retry")
+	}
+	request := callback.Request()
+	if request.Text != "weather melbourne" {
+		t.Errorf("Expected the mention to be stripped, actual %v", request.Text)
+	}
+	if request.UserID != "U1" || request.ChannelID != "C1" || request.TeamID != "T1" {
+		t.Error("The request details don't match the event")
+	}
+}
+
+func TestEventIsCommand(t *testing.T) {
+	var commandTests = []struct {
+		body     string
+		expected bool
+	}{
+		{`{"type":"url_verification","challenge":"abc"}`, false},
+		{`{"type":"event_callback","event":{"type":"app_mention","text":"<@U0IGOR> help"}}`, true},
+		{`{"type":"event_callback","event":{"type":"message","channel_type":"im","text":"help"}}`, true},
+		{`{"type":"event_callback","event":{"type":"message","channel_type":"channel","text":"help"}}`, false},
+		{`{"type":"event_callback","event":{"type":"message","channel_type":"im","bot_id":"B1","text":"help"}}`, false},
+		{`{"type":"event_callback","event":{"type":"message","channel_type":"im","subtype":"message_changed"}}`, false},
+	}
+
+	for _, tt := range commandTests {
+		callback, err := slack.LoadEventCallback(tt.body, nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err.Error())
+		}
+		if callback.IsCommand() != tt.expected {
+			t.Errorf("IsCommand(%v): expected %v, actual %v", tt.body, tt.expected, callback.IsCommand())
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Package slack provides all the Slack specific code for Igor
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+
+	"github.com/ArjenSchwarz/igor/helpers"
+)
+
+// Interaction contains the information sent by Slack when a user clicks a
+// button in one of Igor's messages. Both legacy interactive messages and
+// block_actions payloads are supported.
+type Interaction struct {
+	Type        string
+	CallbackID  string
+	Action      Action
+	ResponseURL string
+	request     Request
+}
+
+// interactionPayload is the JSON structure of the payload Slack sends
+type interactionPayload struct {
+	Type       string `json:"type"`
+	Token      string `json:"token"`
+	CallbackID string `json:"callback_id"`
+	Team       struct {
+		ID     string `json:"id"`
+		Domain string `json:"domain"`
+	} `json:"team"`
+	Channel struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"channel"`
+	User struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Actions []struct {
+		Name     string `json:"name"`
+		ActionID string `json:"action_id"`
+		BlockID  string `json:"block_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	ResponseURL string `json:"response_url"`
+}
+
+// LoadInteraction translates the body and headers of a request sent by Slack
+// for an interactive component into an Interaction
+func LoadInteraction(body string, headers map[string]string) (Interaction, error) {
+	interaction := Interaction{}
+	parsedQuery, err := url.ParseQuery(body)
+	if err != nil {
+		return interaction, err
+	}
+	payload := interactionPayload{}
+	if err := json.Unmarshal([]byte(parsedQuery.Get("payload")), &payload); err != nil {
+		return interaction, err
+	}
+	if len(payload.Actions) == 0 {
+		return interaction, errors.New("No action in the payload")
+	}
+	interaction.Type = payload.Type
+	interaction.ResponseURL = payload.ResponseURL
+	action := payload.Actions[0]
+	interaction.CallbackID = payload.CallbackID
+	interaction.Action = Action{Name: action.Name, Value: action.Value}
+	if payload.Type == "block_actions" {
+		interaction.CallbackID = blockCallback(action.BlockID)
+		interaction.Action.Name = action.ActionID
+	}
+	userName := payload.User.Name
+	if payload.User.Username != "" {
+		userName = payload.User.Username
+	}
+	interaction.request = Request{
+		Token:       payload.Token,
+		TeamID:      payload.Team.ID,
+		TeamDomain:  payload.Team.Domain,
+		ChannelID:   payload.Channel.ID,
+		ChannelName: payload.Channel.Name,
+		UserID:      payload.User.ID,
+		UserName:    userName,
+		ResponseURL: payload.ResponseURL,
+		Platform:    "slack",
+		rawBody:     body,
+		signature:   helpers.GetHeader(headers, SignatureHeader),
+		timestamp:   helpers.GetHeader(headers, TimestampHeader),
+	}
+	return interaction, nil
+}
+
+// Request returns the user and channel details of the interaction as a
+// Request, so it can be validated and passed on to plugins
+func (interaction Interaction) Request() Request {
+	return interaction.request
+}
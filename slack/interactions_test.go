@@ -0,0 +1,50 @@
+package slack_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+func TestLoadInteraction(t *testing.T) {
+	var interactionTests = []struct {
+		payload  string
+		callback string
+		action   string
+		value    string
+		userName string
+	}{
+		{`{"type":"interactive_message","callback_id":"xkcd","actions":[{"name":"next","type":"button","value":"101"}],
+			"user":{"id":"U1","name":"testuser"},"channel":{"id":"C1","name":"general"},"response_url":"https://hooks.slack.com/x"}`,
+			"xkcd", "next", "101", "testuser"},
+		{`{"type":"block_actions","actions":[{"action_id":"forget","block_id":"remember","value":"cat"}],
+			"user":{"id":"U1","username":"testuser","name":"test"},"channel":{"id":"C1","name":"general"},"response_url":"https://hooks.slack.com/x"}`,
+			"remember", "forget", "cat", "testuser"},
+		{`{"type":"block_actions","actions":[{"action_id":"next","block_id":"xkcd/2","value":"102"}],
+			"user":{"id":"U1","username":"testuser"},"channel":{"id":"C1","name":"general"},"response_url":"https://hooks.slack.com/x"}`,
+			"xkcd", "next", "102", "testuser"},
+	}
+
+	for _, tt := range interactionTests {
+		body := url.Values{"payload": {tt.payload}}.Encode()
+		interaction, err := slack.LoadInteraction(body, nil)
+		if err != nil {
+			t.Fatal("Unexpected error", err.Error())
+		}
+		if interaction.CallbackID != tt.callback {
+			t.Errorf("Expected callback %v, actual %v", tt.callback, interaction.CallbackID)
+		}
+		if interaction.Action.Name != tt.action || interaction.Action.Value != tt.value {
+			t.Errorf("Expected action %v:%v, actual %v:%v", tt.action, tt.value, interaction.Action.Name, interaction.Action.Value)
+		}
+		request := interaction.Request()
+		if request.UserName != tt.userName || request.ChannelID != "C1" || request.ResponseURL == "" {
+			t.Error("The request details don't match the payload")
+		}
+	}
+
+	if _, err := slack.LoadInteraction(url.Values{"payload": {`{"type":"block_actions"}`}}.Encode(), nil); err == nil {
+		t.Error("Expected an error for a payload without actions")
+	}
+}
@@ -3,8 +3,10 @@ package slack
 
 import (
 	"net/url"
+	"time"
 
 	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/helpers"
 )
 
 // Request contains the information sent through the request from Slack
@@ -19,6 +21,22 @@ type Request struct {
 	Command     string
 	Text        string
 	ResponseURL string
+	Platform    string
+	RequestID   string
+	rawBody     string
+	signature   string
+	timestamp   string
+}
+
+// LoadRequest translates the body and headers of a request sent by Slack into
+// a Request struct. Unlike LoadRequestFromQuery it keeps the information
+// required to verify the request signature.
+func LoadRequest(body string, headers map[string]string) Request {
+	request := LoadRequestFromQuery(body)
+	request.rawBody = body
+	request.signature = helpers.GetHeader(headers, SignatureHeader)
+	request.timestamp = helpers.GetHeader(headers, TimestampHeader)
+	return request
 }
 
 // LoadRequestFromQuery translates the query string sent by Slack into a Request struct
@@ -38,9 +56,14 @@ func LoadRequestFromQuery(query string) Request {
 	return request
 }
 
-// Validate ensures the request comes from the configured Slack team
+// Validate ensures the request comes from the configured Slack team.
+// If a signing secret is configured the request signature is verified,
+// otherwise it falls back to comparing the legacy verification token.
 func (request *Request) Validate(config config.Config) bool {
-	return request.Token == config.Token
+	if config.SigningSecret != "" {
+		return VerifySignature(config.SigningSecret, request.rawBody, request.timestamp, request.signature, time.Now()) == nil
+	}
+	return config.Token != "" && request.Token == config.Token
 }
 
 // UserInList checks if the request's user is in the provided list
@@ -0,0 +1,64 @@
+package slack_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ArjenSchwarz/igor/config"
+	"github.com/ArjenSchwarz/igor/slack"
+)
+
+const testBody = "token=testtoken&team_id=T1&user_id=U1&user_name=testuser&command=%2Figor&text=help"
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	oldTimestamp := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+	signature := slack.Sign("secret", testBody, timestamp)
+	var signatureTests = []struct {
+		body      string
+		timestamp string
+		signature string
+		expected  error
+	}{
+		{testBody, timestamp, signature, nil},
+		{testBody, "", signature, slack.ErrMissingSignature},
+		{testBody, timestamp, "", slack.ErrMissingSignature},
+		{testBody, "notanumber", signature, slack.ErrMissingSignature},
+		{testBody, oldTimestamp, slack.Sign("secret", testBody, oldTimestamp), slack.ErrExpiredRequest},
+		{testBody + "&extra=1", timestamp, signature, slack.ErrInvalidSignature},
+		{testBody, timestamp, slack.Sign("othersecret", testBody, timestamp), slack.ErrInvalidSignature},
+	}
+
+	for _, tt := range signatureTests {
+		actual := slack.VerifySignature("secret", tt.body, tt.timestamp, tt.signature, now)
+		if actual != tt.expected {
+			t.Errorf("VerifySignature(%v, %v): expected %v, actual %v", tt.timestamp, tt.signature, tt.expected, actual)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	headers := map[string]string{
+		"x-slack-request-timestamp": timestamp,
+		"x-slack-signature":         slack.Sign("secret", testBody, timestamp),
+	}
+	request := slack.LoadRequest(testBody, headers)
+	if !request.Validate(config.Config{SigningSecret: "secret"}) {
+		t.Error("Expected a correctly signed request to validate")
+	}
+	if request.Validate(config.Config{SigningSecret: "othersecret", Token: "testtoken"}) {
+		t.Error("Expected the signature to take precedence over the token")
+	}
+	if !request.Validate(config.Config{Token: "testtoken"}) {
+		t.Error("Expected the token to validate without a signing secret")
+	}
+	if request.Validate(config.Config{Token: "wrongtoken"}) {
+		t.Error("Expected an invalid token to fail validation")
+	}
+	if (&slack.Request{}).Validate(config.Config{}) {
+		t.Error("Expected an empty token to fail validation")
+	}
+}
@@ -14,16 +14,23 @@ const ResponseWarning = "warning"
 // ResponseBad returns the color code for negative responses
 const ResponseBad = "danger"
 
-// Response contains the fields for returning a response message to Slack
+// Response contains the fields for returning a response message to Slack.
+// The Blocks are used by Slack instead of the Attachments when present, while
+// the Attachments are used for platforms that don't support Block Kit.
 type Response struct {
 	Text         string       `json:"text"`
 	ResponseType string       `json:"response_type,omitempty"`
 	Attachments  []Attachment `json:"attachments,omitempty"`
+	Blocks       Blocks       `json:"blocks,omitempty"`
 	UnfurlLinks  bool         `json:"unfurl_links,omitempty"`
 	UnfurlMedia  bool         `json:"unfurl_media,omitempty"`
 	Markdown     bool         `json:"mrkdwn,omitempty"`
 	Username     string       `json:"username,omitempty"`
 	IconEmoji    string       `json:"icon_emoji,omitempty"`
+	// ReplaceOriginal and DeleteOriginal are used when responding to an
+	// action, to change the message that contained the action
+	ReplaceOriginal bool `json:"replace_original,omitempty"`
+	DeleteOriginal  bool `json:"delete_original,omitempty"`
 }
 
 // Attachment contains the fields for a slack attachment
@@ -41,6 +48,8 @@ type Attachment struct {
 	ImageURL   string   `json:"image_url,omitempty"`
 	ThumbURL   string   `json:"thumb_url,omitempty"`
 	Fields     []Field  `json:"fields,omitempty"`
+	CallbackID string   `json:"callback_id,omitempty"`
+	Actions    []Action `json:"actions,omitempty"`
 }
 
 // Field contains the fields for a field within a slack attachment
@@ -50,6 +59,48 @@ type Field struct {
 	Short bool   `json:"short,omitempty"`
 }
 
+// Action contains the fields for an interactive button within a slack
+// attachment. When it's clicked, the Name and Value are sent back to Igor.
+type Action struct {
+	Name    string        `json:"name"`
+	Text    string        `json:"text"`
+	Type    string        `json:"type"`
+	Value   string        `json:"value,omitempty"`
+	Style   string        `json:"style,omitempty"`
+	Confirm *Confirmation `json:"confirm,omitempty"`
+}
+
+// Confirmation contains the fields for a confirmation dialog shown before an
+// action is sent
+type Confirmation struct {
+	Title       string `json:"title,omitempty"`
+	Text        string `json:"text"`
+	OkText      string `json:"ok_text,omitempty"`
+	DismissText string `json:"dismiss_text,omitempty"`
+}
+
+// ActionDanger is the style for buttons with destructive actions
+const ActionDanger = "danger"
+
+// ActionPrimary is the style for the main button
+const ActionPrimary = "primary"
+
+// NewButton creates a button Action
+func NewButton(name string, text string, value string) Action {
+	return Action{Name: name, Text: text, Type: "button", Value: value}
+}
+
+// AddAction adds an action to the attachment. The callback is the name of the
+// plugin that handles the action.
+func (a *Attachment) AddAction(callback string, action Action) {
+	a.CallbackID = callback
+	var actions []Action
+	if a.Actions != nil {
+		actions = a.Actions
+	}
+	a.Actions = append(actions, action)
+}
+
 // EnableMarkdownFor enables Markdown for a part of the attachment
 func (a *Attachment) EnableMarkdownFor(value string) {
 	//TODO validation
@@ -84,6 +135,11 @@ func (response *Response) SetPublic() {
 	response.ResponseType = "in_channel"
 }
 
+// SetEphemeral configures the response to only show up for the user
+func (response *Response) SetEphemeral() {
+	response.ResponseType = "ephemeral"
+}
+
 // IsPublic returns whether the response is configured to show up publicly
 func (response *Response) IsPublic() bool {
 	return response.ResponseType == "in_channel"
@@ -103,6 +159,14 @@ func NothingFoundResponse(request Request) Response {
 	return response
 }
 
+// DelayedResponse is a specific response for when the request is handled in
+// the background and the actual response will be sent later
+func DelayedResponse() Response {
+	response := Response{}
+	response.Text = "Igor is on it. The result will follow shortly."
+	return response
+}
+
 // ValidationErrorResponse is a specific response for when validation failed
 func ValidationErrorResponse() Response {
 	response := Response{}
@@ -110,13 +174,17 @@ func ValidationErrorResponse() Response {
 	return response
 }
 
-// SomethingWrongResponse is a specific response for when an error occurred
+// SomethingWrongResponse is a specific response for when an error occurred.
+// The ID of the request is included, so it can be found in the logs.
 func SomethingWrongResponse(request Request) Response {
 	response := Response{}
 	response.Text = "Oops! Something went wrong with your request."
 	attach := Attachment{Color: "danger"}
 	attach.Text = "You tried to look for *" + request.Command + " " + request.Text + "\n"
 	attach.Text += "Unfortunately, an error occurred while trying to do so. Please try again"
+	if request.RequestID != "" {
+		attach.Text += "\nIf the problem persists, mention reference `" + request.RequestID + "`"
+	}
 	attach.EnableMarkdownFor("text")
 	response.AddAttachment(attach)
 	return response
@@ -139,13 +207,15 @@ func EscapeString(toEscape string) string {
 // Escape escapes all values in the Response that need to be escaped
 func (response *Response) Escape() {
 	response.Text = EscapeString(response.Text)
-	for _, attach := range response.Attachments {
+	for i := range response.Attachments {
+		attach := &response.Attachments[i]
 		attach.Title = EscapeString(attach.Title)
 		attach.Text = EscapeString(attach.Text)
 		attach.PreText = EscapeString(attach.PreText)
-		for _, field := range attach.Fields {
-			field.Title = EscapeString(field.Title)
-			field.Value = EscapeString(field.Value)
+		for j := range attach.Fields {
+			attach.Fields[j].Title = EscapeString(attach.Fields[j].Title)
+			attach.Fields[j].Value = EscapeString(attach.Fields[j].Value)
 		}
 	}
+	escapeBlocks(response.Blocks)
 }
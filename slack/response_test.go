@@ -1,8 +1,10 @@
 package slack_test
 
 import (
-	"github.com/ArjenSchwarz/igor/slack"
+	"strings"
 	"testing"
+
+	"github.com/ArjenSchwarz/igor/slack"
 )
 
 var escapeTests = []struct {
@@ -25,3 +27,14 @@ func TestEscapeString(t *testing.T) {
 		}
 	}
 }
+
+func TestSomethingWrongResponse(t *testing.T) {
+	response := slack.SomethingWrongResponse(slack.Request{Command: "/igor", Text: "weather", RequestID: "ab12cd34"})
+	if !strings.Contains(response.Attachments[0].Text, "`ab12cd34`") {
+		t.Errorf("Expected the request ID as reference, actual %v", response.Attachments[0].Text)
+	}
+	response = slack.SomethingWrongResponse(slack.Request{Command: "/igor", Text: "weather"})
+	if strings.Contains(response.Attachments[0].Text, "reference") {
+		t.Errorf("Expected no reference without a request ID, actual %v", response.Attachments[0].Text)
+	}
+}
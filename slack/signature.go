@@ -0,0 +1,65 @@
+// Package slack provides all the Slack specific code for Igor
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// SignatureHeader is the header Slack uses to send the request signature
+const SignatureHeader = "X-Slack-Signature"
+
+// TimestampHeader is the header Slack uses to send the request timestamp
+const TimestampHeader = "X-Slack-Request-Timestamp"
+
+// signatureVersion is the version of the signing scheme used by Slack
+const signatureVersion = "v0"
+
+// MaxRequestAge is the maximum difference between the request timestamp and
+// the current time. Requests outside this window are rejected to prevent
+// replay attacks.
+var MaxRequestAge = 5 * time.Minute
+
+// ErrMissingSignature is returned when the signature or timestamp is missing
+var ErrMissingSignature = errors.New("Missing request signature")
+
+// ErrExpiredRequest is returned when the request timestamp is outside of
+// the allowed window
+var ErrExpiredRequest = errors.New("Request timestamp outside of allowed window")
+
+// ErrInvalidSignature is returned when the signature doesn't match the body
+var ErrInvalidSignature = errors.New("Invalid request signature")
+
+// VerifySignature verifies the signature Slack sent along with the request
+// against the signing secret. The signature is a HMAC-SHA256 of the version,
+// timestamp, and raw body of the request.
+func VerifySignature(secret string, body string, timestamp string, signature string, now time.Time) error {
+	if timestamp == "" || signature == "" {
+		return ErrMissingSignature
+	}
+	unixTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrMissingSignature
+	}
+	age := now.Sub(time.Unix(unixTime, 0))
+	if age > MaxRequestAge || age < -MaxRequestAge {
+		return ErrExpiredRequest
+	}
+	expected := Sign(secret, body, timestamp)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Sign creates the signature for the provided body and timestamp in the same
+// way Slack does
+func Sign(secret string, body string, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signatureVersion + ":" + timestamp + ":" + body))
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}